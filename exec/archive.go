@@ -0,0 +1,251 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gzipMagic is the header of the gzip compressed file
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// inspectRelease returns the root directory of the chaosblade release, the release is a tar file, a gzip compressed
+// tar file or an unpacked directory, and it must contain the bin directory and the blade tool
+func inspectRelease(releaseFile string) (string, error) {
+	info, err := os.Stat(releaseFile)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		if binInfo, err := os.Stat(filepath.Join(releaseFile, "bin")); err != nil || !binInfo.IsDir() {
+			return "", fmt.Errorf("the bin directory is not found in %s", releaseFile)
+		}
+		if bladeInfo, err := os.Stat(filepath.Join(releaseFile, "blade")); err != nil || bladeInfo.IsDir() {
+			return "", fmt.Errorf("the blade tool is not found in %s", releaseFile)
+		}
+		return filepath.Base(filepath.Clean(releaseFile)), nil
+	}
+	file, err := os.Open(releaseFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	tarReader, closeFunc, err := newTarReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer closeFunc()
+	root := ""
+	hasBin, hasBlade := false, false
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read %s failed, %v", releaseFile, err)
+		}
+		name := strings.Trim(strings.TrimPrefix(header.Name, "./"), "/")
+		if name == "" || name == "." {
+			continue
+		}
+		elements := strings.SplitN(name, "/", 2)
+		if root == "" {
+			root = elements[0]
+		} else if root != elements[0] {
+			return "", fmt.Errorf("multiple root directories %s and %s are found in %s", root, elements[0], releaseFile)
+		}
+		if len(elements) < 2 {
+			continue
+		}
+		switch {
+		case elements[1] == "bin" || strings.HasPrefix(elements[1], "bin/"):
+			hasBin = true
+		case elements[1] == "blade" && header.Typeflag != tar.TypeDir:
+			hasBlade = true
+		}
+	}
+	if root == "" {
+		return "", fmt.Errorf("%s is empty", releaseFile)
+	}
+	if !hasBin {
+		return "", fmt.Errorf("the bin directory is not found in %s", releaseFile)
+	}
+	if !hasBlade {
+		return "", fmt.Errorf("the blade tool is not found in %s", releaseFile)
+	}
+	return root, nil
+}
+
+// openRelease returns the tar stream of the chaosblade release whose root directory srcRoot is renamed to the
+// dstRoot, so the directory does not need to be renamed by the shell after copied
+func openRelease(releaseFile, srcRoot, dstRoot string) (io.ReadCloser, error) {
+	info, err := os.Stat(releaseFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return tarDir(releaseFile, dstRoot), nil
+	}
+	file, err := os.Open(releaseFile)
+	if err != nil {
+		return nil, err
+	}
+	tarReader, closeFunc, err := newTarReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		defer file.Close()
+		defer closeFunc()
+		pipeWriter.CloseWithError(renameTarRoot(tarReader, tar.NewWriter(pipeWriter), srcRoot, dstRoot))
+	}()
+	return pipeReader, nil
+}
+
+// newTarReader returns the tar reader of the file, the file is decompressed if it is compressed by gzip
+func newTarReader(reader io.Reader) (*tar.Reader, func() error, error) {
+	bufReader := bufio.NewReader(reader)
+	header, err := bufReader.Peek(len(gzipMagic))
+	if err == nil && bytes.Equal(header, gzipMagic) {
+		gzipReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gzipReader), gzipReader.Close, nil
+	}
+	return tar.NewReader(bufReader), func() error { return nil }, nil
+}
+
+// renameTarRoot copies the entries of the tarReader to the tarWriter with the root directory renamed, the targets of
+// the hard links are renamed too, because they are the names in the archive. The entry of the archive root itself,
+// such as ./, is skipped.
+func renameTarRoot(tarReader *tar.Reader, tarWriter *tar.Writer, srcRoot, dstRoot string) error {
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		header.Name = renameRoot(header.Name, srcRoot, dstRoot)
+		if header.Name == "" || header.Name == "." {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = renameRoot(header.Linkname, srcRoot, dstRoot)
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// renameRoot replaces the first element of the name if it is the srcRoot
+func renameRoot(name, srcRoot, dstRoot string) string {
+	name = strings.TrimPrefix(name, "./")
+	if name == srcRoot || strings.HasPrefix(name, srcRoot+"/") {
+		return dstRoot + strings.TrimPrefix(name, srcRoot)
+	}
+	return name
+}
+
+// tarDir returns the tar stream of the directory whose root directory is named dstRoot
+func tarDir(dir, dstRoot string) io.ReadCloser {
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		tarWriter := tar.NewWriter(pipeWriter)
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = path.Join(dstRoot, filepath.ToSlash(relPath))
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			return copyFile(tarWriter, file)
+		})
+		if err == nil {
+			err = tarWriter.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+	return pipeReader
+}
+
+func copyFile(writer io.Writer, file string) error {
+	reader, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+// newDirTar returns the tar stream which only contains the directory
+func newDirTar(dir string) (io.Reader, error) {
+	buffer := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buffer)
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:     strings.TrimPrefix(path.Clean(dir), "/") + "/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	}); err != nil {
+		return nil, err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
@@ -0,0 +1,247 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tarEntry is the entry written to the test tar file, the entry is a directory if the name ends with a slash
+type tarEntry struct {
+	name    string
+	content string
+}
+
+func newTar(t *testing.T, entries []tarEntry) []byte {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0755, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		if entry.name[len(entry.name)-1] == '/' {
+			header.Typeflag = tar.TypeDir
+			header.Size = 0
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	if _, err := gzipWriter.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestInspectRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chaosblade-release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	release := []tarEntry{
+		{"chaosblade-1.7.3/", ""},
+		{"chaosblade-1.7.3/bin/", ""},
+		{"chaosblade-1.7.3/bin/chaos_os", "chaos_os"},
+		{"chaosblade-1.7.3/blade", "blade"},
+	}
+	releaseDir := filepath.Join(dir, "chaosblade-dir")
+	if err := os.MkdirAll(filepath.Join(releaseDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(releaseDir, "blade"), []byte("blade"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "tar", file: "release.tar", data: newTar(t, release), want: "chaosblade-1.7.3"},
+		{name: "gzip compressed tar", file: "release.tar.gz", data: gzipBytes(t, newTar(t, release)), want: "chaosblade-1.7.3"},
+		{name: "dot prefixed entries", file: "dot.tar", want: "chaosblade-1.7.3", data: newTar(t, []tarEntry{
+			{"./", ""},
+			{"./chaosblade-1.7.3/bin/chaos_os", "chaos_os"},
+			{"./chaosblade-1.7.3/blade", "blade"},
+		})},
+		{name: "unpacked directory", file: "chaosblade-dir", want: "chaosblade-dir"},
+		{name: "multiple root directories", file: "multiple.tar", wantErr: true, data: newTar(t, []tarEntry{
+			{"chaosblade-1.7.3/bin/chaos_os", "chaos_os"},
+			{"chaosblade-1.7.3/blade", "blade"},
+			{"other/blade", "blade"},
+		})},
+		{name: "no bin directory", file: "nobin.tar", wantErr: true, data: newTar(t, []tarEntry{
+			{"chaosblade-1.7.3/blade", "blade"},
+		})},
+		{name: "no blade tool", file: "noblade.tar", wantErr: true, data: newTar(t, []tarEntry{
+			{"chaosblade-1.7.3/bin/chaos_os", "chaos_os"},
+			{"chaosblade-1.7.3/blade/", ""},
+		})},
+		{name: "empty", file: "empty.tar", wantErr: true, data: newTar(t, nil)},
+		{name: "not exist", file: "notexist.tar", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.file)
+			if tt.data != nil {
+				if err := ioutil.WriteFile(file, tt.data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := inspectRelease(file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("inspectRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("inspectRelease() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameTarRoot(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		want    []tarEntry
+	}{
+		{
+			name: "rename the root directory",
+			entries: []tarEntry{
+				{"chaosblade-1.7.3/", ""},
+				{"chaosblade-1.7.3/bin/chaos_os", "chaos_os"},
+				{"./chaosblade-1.7.3/blade", "blade"},
+			},
+			want: []tarEntry{
+				{"chaosblade/", ""},
+				{"chaosblade/bin/chaos_os", "chaos_os"},
+				{"chaosblade/blade", "blade"},
+			},
+		},
+		{
+			name: "keep the other directories and the same prefix",
+			entries: []tarEntry{
+				{"chaosblade-1.7.3-linux/blade", "blade"},
+				{"other/blade", "blade"},
+			},
+			want: []tarEntry{
+				{"chaosblade-1.7.3-linux/blade", "blade"},
+				{"other/blade", "blade"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			err := renameTarRoot(tar.NewReader(bytes.NewReader(newTar(t, tt.entries))), tar.NewWriter(output),
+				"chaosblade-1.7.3", "chaosblade")
+			if err != nil {
+				t.Fatalf("renameTarRoot() error = %v", err)
+			}
+			got := make([]tarEntry, 0)
+			tarReader := tar.NewReader(output)
+			for {
+				header, err := tarReader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, err := ioutil.ReadAll(tarReader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, tarEntry{header.Name, string(content)})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("renameTarRoot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameTarRootLinks(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	headers := []*tar.Header{
+		{Name: "./", Mode: 0755, Typeflag: tar.TypeDir},
+		{Name: "./chaosblade-1.7.3/", Mode: 0755, Typeflag: tar.TypeDir},
+		{Name: "./chaosblade-1.7.3/bin/chaos_os", Mode: 0755, Typeflag: tar.TypeReg},
+		{Name: "./chaosblade-1.7.3/bin/chaos_os_link", Typeflag: tar.TypeLink, Linkname: "./chaosblade-1.7.3/bin/chaos_os"},
+		{Name: "./chaosblade-1.7.3/bin/chaos_os_symlink", Typeflag: tar.TypeSymlink, Linkname: "chaos_os"},
+	}
+	for _, header := range headers {
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	err := renameTarRoot(tar.NewReader(buffer), tar.NewWriter(output), "chaosblade-1.7.3", "chaosblade")
+	if err != nil {
+		t.Fatalf("renameTarRoot() error = %v", err)
+	}
+	// the name and the link name of the entries
+	got := make([][2]string, 0)
+	tarReader := tar.NewReader(output)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, [2]string{header.Name, header.Linkname})
+	}
+	want := [][2]string{
+		{"chaosblade/", ""},
+		{"chaosblade/bin/chaos_os", ""},
+		{"chaosblade/bin/chaos_os_link", "chaosblade/bin/chaos_os"},
+		{"chaosblade/bin/chaos_os_symlink", "chaos_os"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("renameTarRoot() = %v, want %v", got, want)
+	}
+}
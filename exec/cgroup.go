@@ -0,0 +1,66 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// cgroupRoot is the mount point of the cgroup filesystems on the host
+const cgroupRoot = "/sys/fs/cgroup"
+
+// isCgroupV2 returns true if the host uses the unified cgroup v2 hierarchy
+func isCgroupV2() bool {
+	return util.IsExist(path.Join(cgroupRoot, "cgroup.controllers"))
+}
+
+// getCgroupPath returns the directory of the cgroup which the process belongs to on the host. The controller is
+// the cgroup v1 controller, it is ignored for the cgroup v2.
+func getCgroupPath(pid int, controller string) (string, error) {
+	bytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	v2 := isCgroupV2()
+	for _, line := range strings.Split(strings.TrimSpace(string(bytes)), "\n") {
+		// the line format is hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if v2 {
+			if fields[0] == "0" && fields[1] == "" {
+				return path.Join(cgroupRoot, fields[2]), nil
+			}
+			continue
+		}
+		for _, c := range strings.Split(fields[1], ",") {
+			if c == controller {
+				return path.Join(cgroupRoot, fields[1], fields[2]), nil
+			}
+		}
+	}
+	if v2 {
+		return "", fmt.Errorf("the cgroup v2 of the process %d is not found", pid)
+	}
+	return "", fmt.Errorf("the %s cgroup of the process %d is not found", controller, pid)
+}
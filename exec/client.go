@@ -19,18 +19,22 @@ package exec
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/docker/docker/pkg/stdcopy"
 
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/versions"
@@ -43,6 +47,42 @@ const (
 	DefaultImageRepo       = "registry.cn-hangzhou.aliyuncs.com/chaosblade/chaosblade-tool"
 )
 
+const (
+	// defaultAPIVersion is the api version used by the client, it is downgraded to the daemon version by ping
+	defaultAPIVersion = "1.24"
+	// pidsLimitAPIVersion is the lowest api version which updates the pids limit, the field is dropped by the daemon
+	// in the lower versions. It is only used by the update of the pids limit, because the zero pids limit removes the
+	// limit since this version and the client types do not omit it.
+	pidsLimitAPIVersion = "1.40"
+)
+
+// the exec is inspected until it is not running, because the streams may be closed before the exit code is set
+const (
+	execInspectRetries  = 50
+	execInspectInterval = 100 * time.Millisecond
+)
+
+// ExecResult is the result of the command executed in the container
+type ExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// Err returns the error of the non-zero exit code, the message is the stderr, or the stdout if the stderr is empty
+func (r ExecResult) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+	if msg := strings.TrimSpace(r.Stderr); msg != "" {
+		return errors.New(msg)
+	}
+	if msg := strings.TrimSpace(r.Stdout); msg != "" {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("the command exited with code %d", r.ExitCode)
+}
+
 var cli *Client
 
 type Client struct {
@@ -71,7 +111,7 @@ func (c *Client) CopyToContainer(ctx context.Context, containerId, srcFile, dstP
 		AllowOverwriteDirWithFile: override,
 		CopyUIDGID:                true,
 	}
-	_, err := c.execContainerPrivileged(containerId, fmt.Sprintf("mkdir -p %s", dstPath))
+	err := c.makeDirInContainer(ctx, containerId, dstPath)
 	if err != nil {
 		return err
 	}
@@ -83,9 +123,71 @@ func (c *Client) CopyToContainer(ctx context.Context, containerId, srcFile, dstP
 	return c.client.CopyToContainer(ctx, containerId, dstPath, file, options)
 }
 
+// copyReleaseToContainer copies the chaosblade release to the dstPath and renames its root directory srcRoot to the
+// dstRoot, so neither the shell nor the tar command is required in the container
+func (c *Client) copyReleaseToContainer(ctx context.Context, containerId, srcFile, srcRoot, dstPath, dstRoot string,
+	override bool) error {
+	err := c.makeDirInContainer(ctx, containerId, dstPath)
+	if err != nil {
+		return err
+	}
+	reader, err := openRelease(srcFile, srcRoot, dstRoot)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return c.client.CopyToContainer(ctx, containerId, dstPath, reader, types.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: override,
+		CopyUIDGID:                true,
+	})
+}
+
+// copyBetweenContainers streams the srcPath of the source container to the dstPath of the target container
+func (c *Client) copyBetweenContainers(ctx context.Context, srcContainerId, srcPath, dstContainerId, dstPath string,
+	override bool) error {
+	reader, _, err := c.client.CopyFromContainer(ctx, srcContainerId, srcPath)
+	if err != nil {
+		logrus.Warningf("Copy from container: %s, path: %s, err: %s", srcContainerId, srcPath, err)
+		return err
+	}
+	defer reader.Close()
+	err = c.makeDirInContainer(ctx, dstContainerId, dstPath)
+	if err != nil {
+		return err
+	}
+	return c.client.CopyToContainer(ctx, dstContainerId, dstPath, reader, types.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: override,
+		CopyUIDGID:                true,
+	})
+}
+
+//existsInContainer returns true if the path exists in the container, it does not require the shell in the container
+func (c *Client) existsInContainer(ctx context.Context, containerId, path string) bool {
+	_, err := c.client.ContainerStatPath(ctx, containerId, path)
+	return err == nil
+}
+
+//makeDirInContainer creates the directory by the archive api if it does not exist, the parent directories are
+//created by the docker daemon
+func (c *Client) makeDirInContainer(ctx context.Context, containerId, dir string) error {
+	if c.existsInContainer(ctx, containerId, dir) {
+		return nil
+	}
+	reader, err := newDirTar(dir)
+	if err != nil {
+		return err
+	}
+	err = c.client.CopyToContainer(ctx, containerId, "/", reader, types.CopyToContainerOptions{})
+	if err != nil {
+		logrus.Warningf("Make directory in container: %s, path: %s, err: %s", containerId, dir, err)
+	}
+	return err
+}
+
 // getContainerById returns the container object by container id
 func (c *Client) getContainerById(containerId string) (types.Container, error, int32) {
 	containers, err := c.client.ContainerList(context.Background(), types.ContainerListOptions{
+		All: true,
 		Filters: filters.NewArgs(
 			filters.Arg("id", containerId),
 		),
@@ -99,8 +201,9 @@ func (c *Client) getContainerById(containerId string) (types.Container, error, i
 	return containers[0], nil, spec.OK.Code
 }
 
-//getContainerByName returns the container object by container name
+//getContainerByName returns the container object by container name, the name must be matched exactly
 func (c *Client) getContainerByName(containerName string) (types.Container, error, int32) {
+	containerName = strings.TrimPrefix(containerName, "/")
 	containers, err := c.client.ContainerList(context.Background(), types.ContainerListOptions{
 		All: true,
 		Filters: filters.NewArgs(
@@ -110,45 +213,229 @@ func (c *Client) getContainerByName(containerName string) (types.Container, erro
 	if err != nil {
 		return types.Container{}, fmt.Errorf(spec.DockerExecFailed.Sprintf("GetContainerList", err)), spec.DockerExecFailed.Code
 	}
-	if containers == nil || len(containers) == 0 {
+	// the name filter of docker is a fuzzy match, so filter the containers again
+	return matchContainerByName(containers, containerName, func(name string) bool {
+		return name == containerName
+	})
+}
+
+//getContainerByNameRegex returns the container object whose whole name matches the regular expression
+func (c *Client) getContainerByNameRegex(expr string) (types.Container, error, int32) {
+	regex, err := compileContainerNameRegex(expr)
+	if err != nil {
+		return types.Container{}, fmt.Errorf(spec.ParameterIllegal.Sprintf(ContainerNameFlag.Name, expr, err)), spec.ParameterIllegal.Code
+	}
+	containers, err := c.client.ContainerList(context.Background(), types.ContainerListOptions{
+		All: true,
+	})
+	if err != nil {
+		return types.Container{}, fmt.Errorf(spec.DockerExecFailed.Sprintf("GetContainerList", err)), spec.DockerExecFailed.Code
+	}
+	return matchContainerByName(containers, expr, regex.MatchString)
+}
+
+//compileContainerNameRegex returns the regular expression which must match the whole container name
+func compileContainerNameRegex(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile(fmt.Sprintf("^(?:%s)$", expr))
+}
+
+//matchContainerByName returns the only container whose name is matched, the ambiguous name is invalid
+func matchContainerByName(containers []types.Container, containerName string, match func(name string) bool) (types.Container, error, int32) {
+	matched := make([]types.Container, 0)
+	candidates := make([]string, 0)
+	for _, container := range containers {
+		for _, name := range container.Names {
+			name = strings.TrimPrefix(name, "/")
+			if match(name) {
+				matched = append(matched, container)
+				candidates = append(candidates, name)
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
 		return types.Container{}, fmt.Errorf(spec.ParameterInvalidDockContainerName.Sprintf("container-name")), spec.ParameterInvalidDockContainerName.Code
 	}
-	return containers[0], nil, spec.OK.Code
+	if len(matched) > 1 {
+		return types.Container{}, fmt.Errorf(spec.ParameterInvalid.Sprintf(ContainerNameFlag.Name, containerName,
+			fmt.Sprintf("the name is ambiguous, candidates: %s", strings.Join(candidates, ", ")))), spec.ParameterInvalid.Code
+	}
+	return matched[0], nil, spec.OK.Code
 }
 
-//ExecuteAndRemove: create and start a container for executing a command, and remove the container
-func (c *Client) executeAndRemove(config *container.Config, hostConfig *container.HostConfig,
-	networkConfig *network.NetworkingConfig, containerName string, removed bool, timeout time.Duration,
-	command string) (containerId string, output string, err error, code int32) {
+//getContainersByLabels returns the running containers which match all the labels, the label format is key or key=value
+func (c *Client) getContainersByLabels(labels []string) ([]types.Container, error, int32) {
+	args := filters.NewArgs()
+	for _, label := range labels {
+		args.Add("label", label)
+	}
+	containers, err := c.client.ContainerList(context.Background(), types.ContainerListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(spec.DockerExecFailed.Sprintf("GetContainerList", err)), spec.DockerExecFailed.Code
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf(spec.ParameterInvalid.Sprintf(ContainerLabelFlag.Name, strings.Join(labels, ","),
+			"can not find running container by labels")), spec.ParameterInvalid.Code
+	}
+	return containers, nil, spec.OK.Code
+}
 
-	logrus.Debugf("command: '%s', image: %s, containerName: %s", command, config.Image, containerName)
+//listContainersByLabels returns all containers which match all the labels, including the stopped containers
+func (c *Client) listContainersByLabels(labels []string) ([]types.Container, error) {
+	args := filters.NewArgs()
+	for _, label := range labels {
+		args.Add("label", label)
+	}
+	containers, err := c.client.ContainerList(context.Background(), types.ContainerListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		logrus.Warningf("List containers by labels: %s, err: %s", strings.Join(labels, ","), err)
+	}
+	return containers, err
+}
+
+//ensureImage pulls the image if it does not exist
+func (c *Client) ensureImage(ref string) error {
 	// check image exists or not
-	_, err = c.getImageByRef(config.Image)
+	if _, err := c.getImageByRef(ref); err == nil {
+		return nil
+	}
+	// pull image if not exists
+	if _, err := c.pullImage(ref); err != nil {
+		return fmt.Errorf(spec.DockerImagePullFailed.Sprintf(err))
+	}
+	return nil
+}
+
+//ensureImagePlatform returns the image of the os/arch platform, the image variant of the platform is pulled by the
+//digest of the ref and tagged with the per-platform tag <ref>-<os>-<arch> if the local image is built for another
+//platform. The ref is never tagged again, because the pull by the digest does not tag it.
+func (c *Client) ensureImagePlatform(ref, platform string) (string, error) {
+	if err := c.ensureImage(ref); err != nil {
+		return "", err
+	}
+	if platform == "" {
+		return ref, nil
+	}
+	imagePlatform, err := c.getImagePlatform(ref)
+	if err != nil {
+		return "", err
+	}
+	if imagePlatform == platform {
+		return ref, nil
+	}
+	platformRef := getPlatformImageRef(ref, platform)
+	if imagePlatform, err := c.getImagePlatform(platformRef); err == nil && imagePlatform == platform {
+		return platformRef, nil
+	}
+	if err := c.checkAPIVersion("1.32", "pull the image of the platform"); err != nil {
+		return "", err
+	}
+	original, err := c.getImageByRef(ref)
+	if err != nil {
+		return "", err
+	}
+	digestRef := getImageDigestRef(ref, original.RepoDigests)
+	if digestRef == "" {
+		return "", fmt.Errorf("the image %s is built for %s and it has no repo digest to pull the variant for %s",
+			ref, imagePlatform, platform)
+	}
+	if _, err := c.pullImageWithPlatform(digestRef, platform); err != nil {
+		return "", fmt.Errorf(spec.DockerImagePullFailed.Sprintf(err))
+	}
+	if err := c.tagImage(digestRef, platformRef); err != nil {
+		return "", err
+	}
+	imagePlatform, err = c.getImagePlatform(platformRef)
+	if err != nil {
+		return "", err
+	}
+	if imagePlatform != platform {
+		return "", fmt.Errorf("the image %s is built for %s, the variant for %s is not found", ref, imagePlatform, platform)
+	}
+	return platformRef, nil
+}
+
+//getPlatformImageRef returns the per-platform tag of the image, for example chaosblade-tool:1.7.0-linux-arm64
+func getPlatformImageRef(ref, platform string) string {
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") {
+		ref = fmt.Sprintf("%s:latest", ref)
+	}
+	return fmt.Sprintf("%s-%s", ref, strings.Replace(platform, "/", "-", -1))
+}
+
+//getImageDigestRef returns the repo digest of the image in the repository of the ref, for example
+//chaosblade-tool@sha256:<digest>, or empty if the image is not pulled from the repository
+func getImageDigestRef(ref string, repoDigests []string) string {
+	named, err := reference.ParseNormalizedNamed(ref)
 	if err != nil {
-		// pull image if not exists
-		_, err := c.pullImage(config.Image)
+		return ""
+	}
+	for _, repoDigest := range repoDigests {
+		digested, err := reference.ParseNormalizedNamed(repoDigest)
 		if err != nil {
-			return "", "", fmt.Errorf(spec.DockerImagePullFailed.Sprintf(err)), spec.DockerImagePullFailed.Code
+			continue
+		}
+		if _, ok := digested.(reference.Digested); ok && digested.Name() == named.Name() {
+			return repoDigest
 		}
 	}
+	return ""
+}
+
+//tagImage tags the source image with the target reference
+func (c *Client) tagImage(source, target string) error {
+	err := c.client.ImageTag(context.Background(), source, target)
+	if err != nil {
+		logrus.Warningf("Tag image: %s, target: %s, err: %s", source, target, err)
+	}
+	return err
+}
+
+//getImagePlatform returns the os/arch platform of the image
+func (c *Client) getImagePlatform(ref string) (string, error) {
+	image, err := c.getImageByRef(ref)
+	if err != nil {
+		return "", err
+	}
+	inspect, err := c.getImageInspectById(image.ID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", inspect.Os, inspect.Architecture), nil
+}
+
+//ExecuteAndRemove: create and start a container for executing a command, and remove the container
+func (c *Client) executeAndRemove(config *container.Config, hostConfig *container.HostConfig,
+	networkConfig *network.NetworkingConfig, containerName string, removed bool, timeout time.Duration,
+	command string) (containerId string, result ExecResult, err error, code int32) {
+
+	logrus.Debugf("command: '%s', image: %s, containerName: %s", command, config.Image, containerName)
+	if err := c.ensureImage(config.Image); err != nil {
+		return "", ExecResult{}, err, spec.DockerImagePullFailed.Code
+	}
 	containerId, err = c.createAndStartContainer(config, hostConfig, networkConfig, containerName)
 	if err != nil {
 		c.stopAndRemoveContainer(containerId, &timeout)
-		return containerId, "", fmt.Errorf(spec.DockerExecFailed.Sprintf("CreateAndStartContainer", err)), spec.DockerExecFailed.Code
+		return containerId, ExecResult{}, fmt.Errorf(spec.DockerExecFailed.Sprintf("CreateAndStartContainer", err)), spec.DockerExecFailed.Code
 	}
 
-	output, err = c.execContainer(containerId, command)
+	result, err = c.execContainerForResult(containerId, []string{"sh", "-c", command})
 	if err != nil {
 		if removed {
 			c.stopAndRemoveContainer(containerId, &timeout)
 		}
-		return containerId, "", fmt.Errorf(spec.DockerExecFailed.Sprintf("ContainerExecCmd", err)), spec.DockerExecFailed.Code
+		return containerId, ExecResult{}, fmt.Errorf(spec.DockerExecFailed.Sprintf("ContainerExecCmd", err)), spec.DockerExecFailed.Code
 	}
-	logrus.Infof("Execute output in container: %s", output)
+	logrus.Infof("Execute output in container: %s, exit code: %d", result.Stdout, result.ExitCode)
 	if removed {
 		c.stopAndRemoveContainer(containerId, &timeout)
 	}
-	return containerId, output, nil, spec.OK.Code
+	return containerId, result, nil, spec.OK.Code
 }
 
 // waitAndGetOutput returns the result
@@ -178,16 +465,57 @@ func containerWait() error {
 //createAndStartContainer
 func (c *Client) createAndStartContainer(config *container.Config, hostConfig *container.HostConfig,
 	networkConfig *network.NetworkingConfig, containerName string) (string, error) {
-	body, err := c.client.ContainerCreate(context.Background(), config, hostConfig, networkConfig, containerName)
+	containerId, err := c.createContainer(config, hostConfig, networkConfig, containerName)
 	if err != nil {
-		logrus.Warningf("Create container: %s, err: %s", containerName, err.Error())
 		return "", err
 	}
-	containerId := body.ID
 	err = c.startContainer(containerId)
 	return containerId, err
 }
 
+//createContainer
+func (c *Client) createContainer(config *container.Config, hostConfig *container.HostConfig,
+	networkConfig *network.NetworkingConfig, containerName string) (string, error) {
+	body, err := c.client.ContainerCreate(context.Background(), config, hostConfig, networkConfig, containerName)
+	if err != nil {
+		logrus.Warningf("Create container: %s, err: %s", containerName, err.Error())
+		return "", err
+	}
+	return body.ID, nil
+}
+
+//commitContainer creates an image from the container filesystem
+func (c *Client) commitContainer(containerId, reference string) (string, error) {
+	resp, err := c.client.ContainerCommit(context.Background(), containerId, types.ContainerCommitOptions{
+		Reference: reference,
+		Comment:   "committed by chaosblade",
+		Pause:     true,
+	})
+	if err != nil {
+		logrus.Warningf("Commit container: %s, err: %s", containerId, err)
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+//disconnectNetwork disconnects the container from the network
+func (c *Client) disconnectNetwork(networkId, containerId string, force bool) error {
+	err := c.client.NetworkDisconnect(context.Background(), networkId, containerId, force)
+	if err != nil {
+		logrus.Warningf("Disconnect container: %s from network: %s, err: %s", containerId, networkId, err)
+	}
+	return err
+}
+
+//connectNetwork connects the container to the network with the endpoint settings
+func (c *Client) connectNetwork(networkId, containerId string, config *network.EndpointSettings) error {
+	err := c.client.NetworkConnect(context.Background(), networkId, containerId, config)
+	if err != nil {
+		logrus.Warningf("Connect container: %s to network: %s, err: %s", containerId, networkId, err)
+	}
+	return err
+}
+
 //startContainer
 func (c *Client) startContainer(containerId string) error {
 	err := c.client.ContainerStart(context.Background(), containerId, types.ContainerStartOptions{})
@@ -216,19 +544,45 @@ func (c *Client) execContainerPrivileged(containerId, command string) (output st
 	})
 }
 
-//execContainer with command which does not contain "sh -c" in the target container
+//execContainerForResult executes the cmd without "sh -c" if the cmd is not wrapped, and returns the structured result
+func (c *Client) execContainerForResult(containerId string, cmd []string) (ExecResult, error) {
+	return c.execContainerWithResult(containerId, types.ExecConfig{
+		AttachStderr: true,
+		AttachStdout: true,
+		Cmd:          cmd,
+	})
+}
+
+//execContainer with command which does not contain "sh -c" in the target container, the stdout is returned if the
+//exit code is zero, otherwise the error
 func (c *Client) execContainerWithConf(containerId, command string, config types.ExecConfig) (output string, err error) {
+	result, err := c.execContainerWithResult(containerId, config)
+	if err != nil {
+		return "", err
+	}
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	if result.Stderr != "" {
+		logrus.Warningf("execute command: %s, stderr: %s", command, result.Stderr)
+	}
+	return result.Stdout, nil
+}
+
+//execContainerWithResult returns the stdout, the stderr and the exit code of the command, the error is only returned
+//if the command cannot be executed
+func (c *Client) execContainerWithResult(containerId string, config types.ExecConfig) (ExecResult, error) {
 	logrus.Infof("execute command: %s", strings.Join(config.Cmd, " "))
 	ctx := context.Background()
 	id, err := c.client.ContainerExecCreate(ctx, containerId, config)
 	if err != nil {
 		logrus.Warningf("Create exec for container: %s, err: %s", containerId, err.Error())
-		return "", err
+		return ExecResult{}, err
 	}
 	resp, err := c.client.ContainerExecAttach(ctx, id.ID, types.ExecStartCheck{})
 	if err != nil {
 		logrus.Warningf("Attach exec for container: %s, err: %s", containerId, err.Error())
-		return "", err
+		return ExecResult{}, err
 	}
 	defer resp.Close()
 	stdout := new(bytes.Buffer)
@@ -236,22 +590,43 @@ func (c *Client) execContainerWithConf(containerId, command string, config types
 	_, err = stdcopy.StdCopy(stdout, stderr, resp.Reader)
 	if err != nil {
 		logrus.Warningf("Attach exec for container: %s, err: %s", containerId, err.Error())
-		return "", err
+		return ExecResult{}, err
+	}
+	inspect, err := waitExecExited(func() (types.ContainerExecInspect, error) {
+		return c.client.ContainerExecInspect(ctx, id.ID)
+	}, execInspectRetries, execInspectInterval)
+	if err != nil {
+		logrus.Warningf("Inspect exec for container: %s, err: %s", containerId, err.Error())
+		return ExecResult{}, err
 	}
-	result := stdout.String()
-	errorMsg := stderr.String()
-	logrus.Debugf("execute result: %s, error msg: %s", result, errorMsg)
-	if errorMsg != "" {
-		return "", fmt.Errorf(errorMsg)
-	} else {
-		return result, nil
+	result := ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: inspect.ExitCode,
 	}
+	logrus.Debugf("execute result: %s, error msg: %s, exit code: %d", result.Stdout, result.Stderr, result.ExitCode)
+	return result, nil
+}
+
+//waitExecExited inspects the exec until it is not running, the error is returned if it is still running after the
+//retries, because its exit code is not set yet
+func waitExecExited(inspectFunc func() (types.ContainerExecInspect, error), retries int,
+	interval time.Duration) (types.ContainerExecInspect, error) {
+	inspect, err := inspectFunc()
+	for retry := 0; err == nil && inspect.Running && retry < retries; retry++ {
+		time.Sleep(interval)
+		inspect, err = inspectFunc()
+	}
+	if err == nil && inspect.Running {
+		err = fmt.Errorf("the command is still running after %d inspections, the exit code is unknown", retries+1)
+	}
+	return inspect, err
 }
 
 //StopContainer
 func (c *Client) stopContainer(containerId string, timeout *time.Duration) error {
 	ctx := context.Background()
-	err := c.client.ContainerStop(ctx, containerId, nil)
+	err := c.client.ContainerStop(ctx, containerId, timeout)
 	if err != nil {
 		logrus.Warningf("Stop container: %s, err: %s", containerId, err)
 		return err
@@ -259,6 +634,174 @@ func (c *Client) stopContainer(containerId string, timeout *time.Duration) error
 	return nil
 }
 
+//pauseContainer freezes all processes in the container
+func (c *Client) pauseContainer(containerId string) error {
+	err := c.client.ContainerPause(context.Background(), containerId)
+	if err != nil {
+		logrus.Warningf("Pause container: %s, err: %s", containerId, err)
+	}
+	return err
+}
+
+//unpauseContainer resumes all processes in the container
+func (c *Client) unpauseContainer(containerId string) error {
+	err := c.client.ContainerUnpause(context.Background(), containerId)
+	if err != nil {
+		logrus.Warningf("Unpause container: %s, err: %s", containerId, err)
+	}
+	return err
+}
+
+//killContainer sends the signal to the init process of the container
+func (c *Client) killContainer(containerId, signal string) error {
+	err := c.client.ContainerKill(context.Background(), containerId, signal)
+	if err != nil {
+		logrus.Warningf("Kill container: %s, signal: %s, err: %s", containerId, signal, err)
+	}
+	return err
+}
+
+//checkAPIVersion returns the error if the api version of the daemon is lower than the version required by the feature
+func (c *Client) checkAPIVersion(version, feature string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	p, err := c.client.Ping(ctx)
+	if err != nil {
+		logrus.Warningf("Ping docker daemon err: %s", err)
+		return err
+	}
+	if versions.LessThan(p.APIVersion, version) {
+		return fmt.Errorf("the docker daemon api version %s is too old to %s, %s or later is required",
+			p.APIVersion, feature, version)
+	}
+	return nil
+}
+
+//updateContainer updates the resources of the running container, the pids limit is updated by the api version
+//pidsLimitAPIVersion
+func (c *Client) updateContainer(containerId string, resources container.Resources) error {
+	apiClient := c.client
+	if resources.PidsLimit != 0 {
+		var err error
+		apiClient, err = client.NewClientWithOpts(client.WithHost(c.client.DaemonHost()),
+			client.WithHTTPClient(c.client.HTTPClient()), client.WithVersion(pidsLimitAPIVersion))
+		if err != nil {
+			logrus.Warningf("Create docker client of the api version %s err: %s", pidsLimitAPIVersion, err)
+			return err
+		}
+	}
+	_, err := apiClient.ContainerUpdate(context.Background(), containerId, container.UpdateConfig{
+		Resources: resources,
+	})
+	if err != nil {
+		logrus.Warningf("Update container: %s, err: %s", containerId, err)
+	}
+	return err
+}
+
+//getHostMemTotal returns the total memory of the docker host
+func (c *Client) getHostMemTotal() (int64, error) {
+	info, err := c.client.Info(context.Background())
+	if err != nil {
+		logrus.Warningf("Get docker info err: %s", err)
+		return 0, err
+	}
+	return info.MemTotal, nil
+}
+
+//getCgroupDriver returns the cgroup driver of the docker daemon, cgroupfs or systemd
+func (c *Client) getCgroupDriver() (string, error) {
+	info, err := c.client.Info(context.Background())
+	if err != nil {
+		logrus.Warningf("Get docker info err: %s", err)
+		return "", err
+	}
+	return info.CgroupDriver, nil
+}
+
+//isLocalDaemon returns true if the docker daemon is connected by the unix socket, so it runs on the same host
+func (c *Client) isLocalDaemon() bool {
+	return strings.HasPrefix(c.client.DaemonHost(), "unix://")
+}
+
+//getDockerRootDir returns the root directory of the docker data
+func (c *Client) getDockerRootDir() (string, error) {
+	info, err := c.client.Info(context.Background())
+	if err != nil {
+		logrus.Warningf("Get docker info err: %s", err)
+		return "", err
+	}
+	return info.DockerRootDir, nil
+}
+
+//getContainerStats returns the current resource usage statistics of the container
+func (c *Client) getContainerStats(containerId string) (types.StatsJSON, error) {
+	statsJSON := types.StatsJSON{}
+	stats, err := c.client.ContainerStats(context.Background(), containerId, false)
+	if err != nil {
+		logrus.Warningf("Get container stats: %s, err: %s", containerId, err)
+		return statsJSON, err
+	}
+	defer stats.Body.Close()
+	if err := json.NewDecoder(stats.Body).Decode(&statsJSON); err != nil {
+		logrus.Warningf("Decode container stats: %s, err: %s", containerId, err)
+		return statsJSON, err
+	}
+	return statsJSON, nil
+}
+
+//waitContainerEvent waits for the event of the container since the time, returns false if the timeout is reached
+func (c *Client) waitContainerEvent(containerId, action string, since time.Time, timeout time.Duration) (events.Message, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	args := filters.NewArgs()
+	args.Add("type", events.ContainerEventType)
+	args.Add("container", containerId)
+	args.Add("event", action)
+	messages, errs := c.client.Events(ctx, types.EventsOptions{
+		Since:   fmt.Sprintf("%d", since.Unix()),
+		Filters: args,
+	})
+	select {
+	case message := <-messages:
+		return message, true, nil
+	case err := <-errs:
+		if ctx.Err() != nil {
+			return events.Message{}, false, nil
+		}
+		logrus.Warningf("Wait container event: %s, action: %s, err: %s", containerId, action, err)
+		return events.Message{}, false, err
+	}
+}
+
+//inspectContainer returns the container details
+func (c *Client) inspectContainer(containerId string) (types.ContainerJSON, error) {
+	containerJSON, err := c.client.ContainerInspect(context.Background(), containerId)
+	if err != nil {
+		logrus.Warningf("Inspect container: %s, err: %s", containerId, err)
+	}
+	return containerJSON, err
+}
+
+//waitContainerHealthy waits until the container is running and its health check is finished, or the timeout is reached
+func (c *Client) waitContainerHealthy(containerId string, timeout time.Duration) (types.ContainerJSON, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		containerJSON, err := c.inspectContainer(containerId)
+		if err != nil {
+			return containerJSON, err
+		}
+		state := containerJSON.State
+		if state != nil && state.Running && (state.Health == nil || state.Health.Status != types.Starting) {
+			return containerJSON, nil
+		}
+		if time.Now().After(deadline) {
+			return containerJSON, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 //StopAndRemoveContainer
 func (c *Client) forceRemoveContainer(containerId string) error {
 	err := c.client.ContainerRemove(context.Background(), containerId, types.ContainerRemoveOptions{
@@ -325,7 +868,14 @@ func (c *Client) deleteImageByImageId(imageId string) error {
 
 //PullImage
 func (c *Client) pullImage(ref string) (string, error) {
-	reader, err := c.client.ImagePull(context.Background(), ref, types.ImagePullOptions{})
+	return c.pullImageWithPlatform(ref, "")
+}
+
+//pullImageWithPlatform pulls the image variant of the os/arch platform
+func (c *Client) pullImageWithPlatform(ref, platform string) (string, error) {
+	reader, err := c.client.ImagePull(context.Background(), ref, types.ImagePullOptions{
+		Platform: platform,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -339,9 +889,9 @@ func checkAndCreateClient(endpoint string, cli *client.Client) (*client.Client,
 	if cli == nil {
 		var err error
 		if endpoint == "" {
-			cli, err = client.NewClientWithOpts(client.FromEnv, client.WithVersion("1.24"))
+			cli, err = client.NewClientWithOpts(client.FromEnv, client.WithVersion(defaultAPIVersion))
 		} else {
-			cli, err = client.NewClientWithOpts(client.FromEnv, client.WithVersion("1.24"), client.WithHost(endpoint))
+			cli, err = client.NewClientWithOpts(client.FromEnv, client.WithVersion(defaultAPIVersion), client.WithHost(endpoint))
 		}
 		if err != nil {
 			return nil, err
@@ -358,22 +908,21 @@ func ping(cli *client.Client) (*client.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 	p, err := cli.Ping(ctx)
-	if err == nil {
-		return cli, nil
-	}
 	if p.APIVersion == "" {
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		return cli, nil
 	}
-	// if server version is lower than the client version, downgrade
+	// if server version is lower than the client version, downgrade, the ping succeeds with any client version
 	if versions.LessThan(p.APIVersion, cli.ClientVersion()) {
 		client.WithVersion(p.APIVersion)(cli)
 		_, err = cli.Ping(ctx)
-		if err == nil {
-			return cli, nil
-		}
+	}
+	if err != nil {
 		return nil, err
 	}
-	return nil, err
+	return cli, nil
 }
 
 func getChaosBladeImageRef(repo, version string) string {
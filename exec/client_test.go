@@ -0,0 +1,215 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/docker/docker/api/types"
+)
+
+func TestExecResultErr(t *testing.T) {
+	tests := []struct {
+		name   string
+		result ExecResult
+		want   string
+	}{
+		{"zero exit code", ExecResult{Stdout: "out", Stderr: "warning", ExitCode: 0}, ""},
+		{"stderr", ExecResult{Stdout: "out", Stderr: " failed\n", ExitCode: 1}, "failed"},
+		{"stdout without stderr", ExecResult{Stdout: "out\n", Stderr: " \n", ExitCode: 2}, "out"},
+		{"no output", ExecResult{ExitCode: 127}, "the command exited with code 127"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.result.Err()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Err() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchContainerByName(t *testing.T) {
+	containers := []types.Container{
+		{ID: "1", Names: []string{"/web"}},
+		{ID: "2", Names: []string{"/web-1"}},
+		{ID: "3", Names: []string{"/web-2"}},
+		{ID: "4", Names: []string{"/db"}},
+	}
+	tests := []struct {
+		name       string
+		expr       string
+		regex      bool
+		want       string
+		code       int32
+		candidates []string
+	}{
+		{name: "exact match", expr: "web", want: "1", code: spec.OK.Code},
+		{name: "exact match with the leading slash", expr: "/db", want: "4", code: spec.OK.Code},
+		{name: "exact no match", expr: "we", code: spec.ParameterInvalidDockContainerName.Code},
+		{name: "anchored regex", expr: "web-[1]", regex: true, want: "2", code: spec.OK.Code},
+		{name: "anchored regex does not match the substring", expr: "eb", regex: true,
+			code: spec.ParameterInvalidDockContainerName.Code},
+		{name: "regex alternation is anchored as a whole", expr: "db|web-2", regex: true, code: spec.ParameterInvalid.Code,
+			candidates: []string{"web-2", "db"}},
+		{name: "ambiguous regex", expr: "web-.*", regex: true, code: spec.ParameterInvalid.Code,
+			candidates: []string{"web-1", "web-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := strings.TrimPrefix(tt.expr, "/")
+			match := func(containerName string) bool {
+				return containerName == name
+			}
+			if tt.regex {
+				regex, err := compileContainerNameRegex(tt.expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				match = regex.MatchString
+			}
+			container, err, code := matchContainerByName(containers, tt.expr, match)
+			if code != tt.code {
+				t.Fatalf("matchContainerByName() code = %d, want %d, err: %v", code, tt.code, err)
+			}
+			if container.ID != tt.want {
+				t.Errorf("matchContainerByName() = %q, want %q", container.ID, tt.want)
+			}
+			for _, candidate := range tt.candidates {
+				if err == nil || !strings.Contains(err.Error(), candidate) {
+					t.Errorf("matchContainerByName() error = %v, want the candidate %s", err, candidate)
+				}
+			}
+		})
+	}
+}
+
+func TestGetImageDigestRef(t *testing.T) {
+	const digest = "sha256:4a5fa4e4d4bbc8ba0e1f1db3e7c29bf6cc1dbb6c0e2d3b5f5b5d6c2bca5e8b2a"
+	repo := "registry.cn-hangzhou.aliyuncs.com/chaosblade/chaosblade-tool"
+	tests := []struct {
+		name        string
+		ref         string
+		repoDigests []string
+		want        string
+	}{
+		{
+			name:        "tagged ref",
+			ref:         repo + ":1.7.3",
+			repoDigests: []string{"busybox@" + digest, repo + "@" + digest},
+			want:        repo + "@" + digest,
+		},
+		{
+			name:        "ref without tag",
+			ref:         repo,
+			repoDigests: []string{repo + "@" + digest},
+			want:        repo + "@" + digest,
+		},
+		{
+			name:        "familiar name of the docker hub",
+			ref:         "docker.io/library/busybox:latest",
+			repoDigests: []string{"busybox@" + digest},
+			want:        "busybox@" + digest,
+		},
+		{
+			name:        "registry with port",
+			ref:         "localhost:5000/chaosblade-tool:1.7.3",
+			repoDigests: []string{"localhost:5000/chaosblade-tool@" + digest},
+			want:        "localhost:5000/chaosblade-tool@" + digest,
+		},
+		{
+			name:        "digest of another repository",
+			ref:         repo + ":1.7.3",
+			repoDigests: []string{"busybox@" + digest},
+		},
+		{
+			name: "image built locally",
+			ref:  "chaosblade-tool:dev",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getImageDigestRef(tt.ref, tt.repoDigests); got != tt.want {
+				t.Errorf("getImageDigestRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitExecExited(t *testing.T) {
+	tests := []struct {
+		name        string
+		inspects    []types.ContainerExecInspect
+		err         error
+		wantExit    int
+		wantErr     bool
+		wantInspect int
+	}{
+		{
+			name:        "exited at once",
+			inspects:    []types.ContainerExecInspect{{ExitCode: 1}},
+			wantExit:    1,
+			wantInspect: 1,
+		},
+		{
+			name:        "exited after the retries",
+			inspects:    []types.ContainerExecInspect{{Running: true}, {Running: true}, {ExitCode: 2}},
+			wantExit:    2,
+			wantInspect: 3,
+		},
+		{
+			name:        "still running after the retries",
+			inspects:    []types.ContainerExecInspect{{Running: true}, {Running: true}, {Running: true}, {Running: true}},
+			wantErr:     true,
+			wantInspect: 4,
+		},
+		{
+			name:        "inspect failed",
+			inspects:    []types.ContainerExecInspect{{}},
+			err:         errors.New("no such exec"),
+			wantErr:     true,
+			wantInspect: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspected := 0
+			inspect, err := waitExecExited(func() (types.ContainerExecInspect, error) {
+				inspected++
+				return tt.inspects[inspected-1], tt.err
+			}, 3, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("waitExecExited() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if inspected != tt.wantInspect {
+				t.Errorf("waitExecExited() inspected %d times, want %d", inspected, tt.wantInspect)
+			}
+			if !tt.wantErr && inspect.ExitCode != tt.wantExit {
+				t.Errorf("waitExecExited() exit code = %d, want %d", inspect.ExitCode, tt.wantExit)
+			}
+		})
+	}
+}
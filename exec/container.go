@@ -18,16 +18,28 @@ package exec
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 )
 
 const (
 	ForceFlag = "force"
+	// TimeoutFlag is the global flag added to all actions by the spec, it is not declared by the actions
+	TimeoutFlag     = "timeout"
+	RecoverableFlag = "recoverable"
+	CommitFlag      = "commit"
 )
 
+// recordKeyRemovedContainers is the record key of the snapshots of the removed containers
+const recordKeyRemovedContainers = "removed-containers"
+
 type ContainerCommandModelSpec struct {
 	spec.BaseExpModelCommandSpec
 }
@@ -37,6 +49,11 @@ func NewContainerCommandSpec() spec.ExpModelCommandSpec {
 		spec.BaseExpModelCommandSpec{
 			ExpActions: []spec.ExpActionCommandSpec{
 				NewRemoveActionCommand(),
+				NewStopActionCommand(),
+				NewPauseActionCommand(),
+				NewKillActionCommand(),
+				NewCrashloopActionCommand(),
+				NewUpdateActionCommand(),
 			},
 			ExpFlags: []spec.ExpFlagSpec{},
 		},
@@ -69,11 +86,27 @@ func NewRemoveActionCommand() spec.ExpActionCommandSpec {
 					Desc:   "force remove",
 					NoArgs: true,
 				},
+				&spec.ExpFlag{
+					Name:   RecoverableFlag,
+					Desc:   "Snapshot the container config before removing, the container is recreated and started with the same name when the experiment is destroyed",
+					NoArgs: true,
+				},
+				&spec.ExpFlag{
+					Name:   CommitFlag,
+					Desc:   "Commit the container filesystem to an image before removing, and recreate the container from it, only works with the recoverable flag",
+					NoArgs: true,
+				},
 			},
 			ActionExecutor: &removeActionExecutor{},
 			ActionExample:
 			`# Delete the container id that is a76d53933d3f",
-blade create docker container remove --container-id a76d53933d3f`,
+blade create docker container remove --container-id a76d53933d3f
+
+# Delete the containers of the web service in the docker compose project demo
+blade create docker container remove --compose-project demo --compose-service web
+
+# Delete the container and recreate it with the same config and filesystem when destroying
+blade create docker container remove --recoverable --commit --container-id a76d53933d3f`,
 			ActionCategories: []string{CategorySystemContainer},
 		},
 	}
@@ -108,32 +141,220 @@ func (*removeActionExecutor) Name() string {
 func (e *removeActionExecutor) SetChannel(channel spec.Channel) {
 }
 
+// containerSnapshot is the config of the removed container which is used to recreate it
+type containerSnapshot struct {
+	Id         string                               `json:"id"`
+	Name       string                               `json:"name"`
+	Image      string                               `json:"image,omitempty"`
+	Config     *container.Config                    `json:"config"`
+	HostConfig *container.HostConfig                `json:"hostConfig"`
+	Networks   map[string]*network.EndpointSettings `json:"networks"`
+	Mounts     []types.MountPoint                   `json:"mounts"`
+}
+
 func (e *removeActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
-	if _, ok := spec.IsDestroy(ctx); ok {
-		return spec.ReturnSuccess(uid)
-	}
 	flags := model.ActionFlags
+	recoverable := flags[RecoverableFlag] == "true"
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		if !recoverable {
+			removeExperimentRecords(uid, suid)
+			return spec.ReturnSuccess(uid)
+		}
+		client, err := GetClient(flags[EndpointFlag.Name])
+		if err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+		}
+		return e.recreateContainers(uid, suid, client)
+	}
 	client, err := GetClient(flags[EndpointFlag.Name])
 	if err != nil {
 		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
 		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
 	}
-	containerId := flags[ContainerIdFlag.Name]
-	containerName := flags[ContainerNameFlag.Name]
-	container, response := GetContainer(client, uid, containerId, containerName)
-	if !response.Success {
-		return response
+	snapshots := make([]containerSnapshot, 0)
+	return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		if recoverable {
+			snapshot, err := e.snapshotContainer(uid, client, container.ID, flags[CommitFlag] == "true")
+			if err != nil {
+				util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SnapshotContainer", err))
+				return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SnapshotContainer", err)
+			}
+			snapshots = append(snapshots, snapshot)
+			if err := saveRecord(uid, recordKeyRemovedContainers, snapshots); err != nil {
+				util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SaveSnapshot", err))
+				return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveSnapshot", err)
+			}
+		}
+		var err error
+		forceFlag := flags[ForceFlag]
+		if forceFlag == "" {
+			// the container is stopped with the grace period of the daemon before it is removed
+			err = client.stopAndRemoveContainer(container.ID, nil)
+		} else {
+			err = client.forceRemoveContainer(container.ID)
+		}
+		if err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerRemove", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerRemove", err)
+		}
+		return spec.ReturnSuccess(uid)
+	})
+}
+
+// snapshotContainer returns the container config, and commits the container filesystem to an image if commit is true
+func (e *removeActionExecutor) snapshotContainer(uid string, client *Client, containerId string,
+	commit bool) (containerSnapshot, error) {
+	containerJSON, err := client.inspectContainer(containerId)
+	if err != nil {
+		return containerSnapshot{}, err
+	}
+	snapshot := containerSnapshot{
+		Id:         containerJSON.ID,
+		Name:       strings.TrimPrefix(containerJSON.Name, "/"),
+		Config:     containerJSON.Config,
+		HostConfig: containerJSON.HostConfig,
+		Networks:   make(map[string]*network.EndpointSettings),
+		Mounts:     containerJSON.Mounts,
 	}
-	forceFlag := flags[ForceFlag]
-	if forceFlag == "" {
-		timeout := time.Second
-		err = client.stopAndRemoveContainer(container.ID, &timeout)
-	} else {
-		err = client.forceRemoveContainer(container.ID)
+	if containerJSON.NetworkSettings != nil {
+		for name, settings := range containerJSON.NetworkSettings.Networks {
+			snapshot.Networks[name] = getEndpointConfig(settings, containerJSON.ID)
+		}
 	}
+	if commit {
+		reference := fmt.Sprintf("chaosblade-recoverable:%s-%s", uid, shortContainerId(containerJSON.ID))
+		imageId, err := client.commitContainer(containerJSON.ID, reference)
+		if err != nil {
+			return containerSnapshot{}, err
+		}
+		snapshot.Image = imageId
+	}
+	return snapshot, nil
+}
+
+// recreateContainers creates and starts the removed containers from the snapshots
+func (e *removeActionExecutor) recreateContainers(uid, suid string, client *Client) *spec.Response {
+	snapshots := make([]containerSnapshot, 0)
+	exists, err := loadRecord(suid, recordKeyRemovedContainers, &snapshots)
 	if err != nil {
-		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerRemove", err))
-		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerRemove", err)
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadSnapshot", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadSnapshot", err)
+	}
+	if !exists {
+		removeExperimentRecords(uid, suid)
+		return spec.ReturnSuccess(uid)
+	}
+	results := make([]ContainerResponse, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		response := e.recreateContainer(uid, client, snapshot)
+		results = append(results, ContainerResponse{
+			ContainerId:   snapshot.Id,
+			ContainerName: snapshot.Name,
+			Code:          response.Code,
+			Success:       response.Success,
+			Err:           response.Err,
+			Result:        response.Result,
+		})
+	}
+	response := aggregateContainerResponses(results, "RecreateContainer")
+	if response.Success {
+		removeExperimentRecords(uid, suid)
+	}
+	return response
+}
+
+// recreateContainer creates the container with the snapshot config, connects it to the networks and starts it
+func (e *removeActionExecutor) recreateContainer(uid string, client *Client, snapshot containerSnapshot) *spec.Response {
+	if _, err := client.inspectContainer(snapshot.Name); err == nil {
+		// the container has been recreated
+		return startContainerAndCheckHealth(uid, client, snapshot.Name)
+	}
+	config := *snapshot.Config
+	if snapshot.Image != "" {
+		config.Image = snapshot.Image
+	}
+	hostConfig := *snapshot.HostConfig
+	hostConfig.Binds = append(hostConfig.Binds, getAnonymousVolumeBinds(snapshot)...)
+	// only one network can be connected when creating the container
+	primaryNetwork := hostConfig.NetworkMode.NetworkName()
+	if hostConfig.NetworkMode.IsDefault() {
+		primaryNetwork = "bridge"
+	}
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: make(map[string]*network.EndpointSettings),
+	}
+	if settings, ok := snapshot.Networks[primaryNetwork]; ok {
+		networkingConfig.EndpointsConfig[primaryNetwork] = settings
+	}
+	containerId, err := client.createContainer(&config, &hostConfig, networkingConfig, snapshot.Name)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerCreate", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerCreate", err)
+	}
+	for name, settings := range snapshot.Networks {
+		if name == primaryNetwork {
+			continue
+		}
+		if err := client.connectNetwork(name, containerId, settings); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("NetworkConnect", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "NetworkConnect", err)
+		}
+	}
+	return startContainerAndCheckHealth(uid, client, containerId)
+}
+
+// getAnonymousVolumeBinds returns the binds of the volumes which are not declared in the host config, so that the
+// recreated container uses the same anonymous volumes instead of creating new ones
+func getAnonymousVolumeBinds(snapshot containerSnapshot) []string {
+	declared := make(map[string]bool)
+	for _, bind := range snapshot.HostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 {
+			declared[parts[1]] = true
+		}
+	}
+	for _, m := range snapshot.HostConfig.Mounts {
+		declared[m.Target] = true
+	}
+	binds := make([]string, 0)
+	for _, m := range snapshot.Mounts {
+		if m.Type != mount.TypeVolume || m.Name == "" || declared[m.Destination] {
+			continue
+		}
+		bind := fmt.Sprintf("%s:%s", m.Name, m.Destination)
+		if !m.RW {
+			bind = bind + ":ro"
+		}
+		binds = append(binds, bind)
+	}
+	return binds
+}
+
+// getEndpointConfig returns the configurable part of the endpoint settings, the operational data is dropped
+func getEndpointConfig(settings *network.EndpointSettings, containerId string) *network.EndpointSettings {
+	if settings == nil {
+		return &network.EndpointSettings{}
+	}
+	aliases := make([]string, 0, len(settings.Aliases))
+	for _, alias := range settings.Aliases {
+		// the short container id is added as an alias by docker automatically
+		if alias != shortContainerId(containerId) {
+			aliases = append(aliases, alias)
+		}
+	}
+	return &network.EndpointSettings{
+		IPAMConfig: settings.IPAMConfig,
+		Links:      settings.Links,
+		Aliases:    aliases,
+		DriverOpts: settings.DriverOpts,
+	}
+}
+
+// shortContainerId returns the first 12 characters of the container id
+func shortContainerId(containerId string) string {
+	if len(containerId) > 12 {
+		return containerId[:12]
 	}
-	return spec.ReturnSuccess(uid)
+	return containerId
 }
@@ -0,0 +1,352 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	IntervalFlag = "interval"
+	CountFlag    = "count"
+)
+
+const (
+	defaultCrashloopInterval = 10
+	recordKeyCrashloop       = "crashloop"
+	recordKeyCrashloopStop   = "crashloop-stop"
+	recordKeyCrashloopLoop   = "crashloop-loop"
+)
+
+// crashloopUidEnv is the environment variable of the uid of the experiment whose crash loop is run by the blade tool
+// started in the background by the create command
+const crashloopUidEnv = "CHAOSBLADE_DOCKER_CRASHLOOP_UID"
+
+const (
+	crashloopEventKill    = "kill"
+	crashloopEventRestart = "restart"
+)
+
+type crashloopActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewCrashloopActionCommand() spec.ExpActionCommandSpec {
+	return &crashloopActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: SignalFlag,
+					Desc: "The signal sent to the init process of the container, default value is SIGKILL",
+				},
+				&spec.ExpFlag{
+					Name: IntervalFlag,
+					Desc: "Seconds between two kills, default value is 10",
+				},
+				&spec.ExpFlag{
+					Name: CountFlag,
+					Desc: "The number of kills, the container is killed until the timeout or the experiment is destroyed if not specified",
+				},
+			},
+			ActionExecutor: &crashloopActionExecutor{},
+			ActionExample: `# Kill the container id that is a76d53933d3f every 10 seconds until the experiment is destroyed
+blade create docker container crashloop --container-id a76d53933d3f
+
+# Kill the container every 10 seconds for 300 seconds
+blade create docker container crashloop --timeout 300 --container-id a76d53933d3f
+
+# Kill the container 5 times, every 30 seconds
+blade create docker container crashloop --interval 30 --count 5 --container-id a76d53933d3f
+
+# Send SIGTERM to the container every 5 seconds for 120 seconds
+blade create docker container crashloop --signal SIGTERM --interval 5 --timeout 120 --container-id a76d53933d3f`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*crashloopActionCommand) Name() string {
+	return "crashloop"
+}
+
+func (*crashloopActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*crashloopActionCommand) ShortDesc() string {
+	return "kill a container repeatedly"
+}
+
+func (c *crashloopActionCommand) LongDesc() string {
+	if c.ActionLongDesc != "" {
+		return c.ActionLongDesc
+	}
+	return "kill the container repeatedly to verify the restart policy and the supervisor back off, every kill and restart is recorded. The crash loop runs in the background until the count or the timeout is reached or the experiment is destroyed, the destroy command returns the recorded events"
+}
+
+type crashloopActionExecutor struct {
+}
+
+// crashloopEvent is a kill or restart of the container in the crash loop
+type crashloopEvent struct {
+	ContainerId  string `json:"containerId"`
+	Event        string `json:"event"`
+	Time         string `json:"time"`
+	RestartCount int    `json:"restartCount"`
+	Err          string `json:"error,omitempty"`
+}
+
+func (*crashloopActionExecutor) Name() string {
+	return "crashloop"
+}
+
+func (e *crashloopActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+// crashloopLoop is the crash loop started by the create command and run in the background
+type crashloopLoop struct {
+	Endpoint     string   `json:"endpoint"`
+	ContainerIds []string `json:"containerIds"`
+	Signal       string   `json:"signal"`
+	Interval     int      `json:"interval"`
+	Count        int      `json:"count"`
+	// Deadline is the unix time in nanoseconds when the loop stops, 0 means no deadline
+	Deadline int64 `json:"deadline"`
+}
+
+func (e *crashloopActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return e.stop(uid, suid, ctx)
+	}
+	if loopUid := os.Getenv(crashloopUidEnv); loopUid != "" {
+		return e.runLoop(uid, loopUid)
+	}
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	loop := crashloopLoop{
+		Endpoint: flags[EndpointFlag.Name],
+		Signal:   flags[SignalFlag],
+		Interval: defaultCrashloopInterval,
+	}
+	if loop.Signal == "" {
+		loop.Signal = defaultKillSignal
+	}
+	if value := flags[IntervalFlag]; value != "" {
+		loop.Interval, err = strconv.Atoi(value)
+		if err != nil || loop.Interval < 1 {
+			util.Errorf(uid, util.GetRunFuncName(), spec.ParameterIllegal.Sprintf(IntervalFlag, value, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, IntervalFlag, value, "it must be a positive integer")
+		}
+	}
+	if value := flags[CountFlag]; value != "" {
+		loop.Count, err = strconv.Atoi(value)
+		if err != nil || loop.Count < 1 {
+			util.Errorf(uid, util.GetRunFuncName(), spec.ParameterIllegal.Sprintf(CountFlag, value, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, CountFlag, value, "it must be a positive integer")
+		}
+	}
+	timeout, response := parseTimeout(flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	if timeout > 0 {
+		loop.Deadline = time.Now().Add(time.Duration(timeout) * time.Second).UnixNano()
+	}
+	response = execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		if container.State != "running" {
+			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is %s", container.ID, container.State))
+			return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", container.State)
+		}
+		loop.ContainerIds = append(loop.ContainerIds, container.ID)
+		return spec.ReturnSuccess(uid)
+	})
+	if len(loop.ContainerIds) == 0 {
+		return response
+	}
+	if startResponse := e.startLoop(uid, ctx, loop); !startResponse.Success {
+		util.Errorf(uid, util.GetRunFuncName(), startResponse.Err)
+		return startResponse
+	}
+	return response
+}
+
+// startLoop records the crash loop and starts the blade tool in the background to run it, so the create command
+// returns immediately, the same as the destroy scheduled by the timeout
+func (e *crashloopActionExecutor) startLoop(uid string, ctx context.Context, loop crashloopLoop) *spec.Response {
+	if err := saveRecord(uid, recordKeyCrashloopLoop, loop); err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveCrashloop", err)
+	}
+	if err := saveRecord(uid, recordKeyCrashloop, make([]crashloopEvent, 0)); err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveCrashloop", err)
+	}
+	bladeBin := path.Join(util.GetProgramPath(), "blade")
+	return channel.NewLocalChannel().Run(ctx, "nohup",
+		fmt.Sprintf(`env %s=%s %s create docker container crashloop --uid %s > /dev/null 2>&1 &`,
+			crashloopUidEnv, uid, bladeBin, getCrashloopLoopUid(uid)))
+}
+
+// getCrashloopLoopUid returns the uid of the blade experiment which runs the crash loop of the experiment
+func getCrashloopLoopUid(uid string) string {
+	return fmt.Sprintf("%s-crashloop", uid)
+}
+
+// runLoop runs the crash loop recorded by the create command of the experiment
+func (e *crashloopActionExecutor) runLoop(uid, loopUid string) *spec.Response {
+	var loop crashloopLoop
+	exists, err := loadRecord(loopUid, recordKeyCrashloopLoop, &loop)
+	if err != nil || !exists {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("load the crashloop record of %s failed, %v", loopUid, err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadCrashloop", fmt.Sprintf("the record of %s is not found", loopUid))
+	}
+	client, err := GetClient(loop.Endpoint)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	return e.start(loopUid, client, loop)
+}
+
+// start kills the containers every interval until the count is reached, the deadline is reached or the experiment
+// is destroyed, and returns all kill and restart events. The destroy command stops the loop by the stop record.
+func (e *crashloopActionExecutor) start(uid string, client *Client, loop crashloopLoop) *spec.Response {
+	defer func() {
+		if err := removeRecords(uid); err != nil {
+			util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the records of %s failed, %v", uid, err))
+		}
+	}()
+	var deadline time.Time
+	if loop.Deadline > 0 {
+		deadline = time.Unix(0, loop.Deadline)
+	}
+	events := make([]crashloopEvent, 0)
+	startedAt := make(map[string]string, len(loop.ContainerIds))
+	for _, containerId := range loop.ContainerIds {
+		if containerJSON, err := client.inspectContainer(containerId); err == nil && containerJSON.State != nil {
+			startedAt[containerId] = containerJSON.State.StartedAt
+		}
+	}
+	interval := time.Duration(loop.Interval) * time.Second
+	for kills := 0; loop.Count == 0 || kills < loop.Count; kills++ {
+		if kills > 0 {
+			time.Sleep(getCrashloopSleep(interval, deadline, time.Now()))
+		}
+		if stopped, _ := loadRecord(uid, recordKeyCrashloopStop, new(bool)); stopped {
+			break
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			break
+		}
+		for _, containerId := range loop.ContainerIds {
+			events = append(events, e.killOnce(uid, client, containerId, loop.Signal, startedAt)...)
+		}
+		if err := saveRecord(uid, recordKeyCrashloop, events); err != nil {
+			util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("save the crashloop record failed, %v", err))
+		}
+	}
+	return spec.ReturnSuccess(events)
+}
+
+// getCrashloopSleep returns the time to sleep before the next kill, the sleep does not pass the deadline
+func getCrashloopSleep(interval time.Duration, deadline, now time.Time) time.Duration {
+	if deadline.IsZero() {
+		return interval
+	}
+	if remaining := deadline.Sub(now); remaining < interval {
+		if remaining < 0 {
+			return 0
+		}
+		return remaining
+	}
+	return interval
+}
+
+// killOnce records the restart of the container since the last kill, and kills it again
+func (e *crashloopActionExecutor) killOnce(uid string, client *Client, containerId, signal string,
+	startedAt map[string]string) []crashloopEvent {
+	events := make([]crashloopEvent, 0, 2)
+	containerJSON, err := client.inspectContainer(containerId)
+	if err != nil {
+		return append(events, crashloopEvent{
+			ContainerId: containerId,
+			Event:       crashloopEventKill,
+			Time:        time.Now().Format(time.RFC3339Nano),
+			Err:         err.Error(),
+		})
+	}
+	if containerJSON.State != nil && containerJSON.State.StartedAt != startedAt[containerId] {
+		startedAt[containerId] = containerJSON.State.StartedAt
+		events = append(events, crashloopEvent{
+			ContainerId:  containerId,
+			Event:        crashloopEventRestart,
+			Time:         containerJSON.State.StartedAt,
+			RestartCount: containerJSON.RestartCount,
+		})
+	}
+	event := crashloopEvent{
+		ContainerId:  containerId,
+		Event:        crashloopEventKill,
+		Time:         time.Now().Format(time.RFC3339Nano),
+		RestartCount: containerJSON.RestartCount,
+	}
+	if containerJSON.State == nil || !containerJSON.State.Running {
+		event.Err = "the container is not running"
+	} else if err := client.killContainer(containerId, signal); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerKill", err))
+		event.Err = err.Error()
+	}
+	return append(events, event)
+}
+
+// stop notifies the running crash loop to stop, destroys the blade experiment running it and returns the events
+// recorded so far
+func (e *crashloopActionExecutor) stop(uid, suid string, ctx context.Context) *spec.Response {
+	events := make([]crashloopEvent, 0)
+	exists, err := loadRecord(suid, recordKeyCrashloop, &events)
+	if err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("load the crashloop record of %s failed, %v", suid, err))
+	}
+	if !exists {
+		// the crash loop is finished, or it is the blade experiment running the crash loop
+		removeExperimentRecords(uid, suid)
+		return spec.ReturnSuccess(events)
+	}
+	if err := saveRecord(suid, recordKeyCrashloopStop, true); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("stop the crashloop of %s failed, %v", suid, err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "StopCrashloop", err)
+	}
+	bladeBin := path.Join(util.GetProgramPath(), "blade")
+	if response := channel.NewLocalChannel().Run(ctx, bladeBin, fmt.Sprintf("destroy %s", getCrashloopLoopUid(suid))); !response.Success {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("destroy the crashloop experiment of %s failed, %s", suid, response.Err))
+	}
+	return spec.ReturnSuccess(events)
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetCrashloopSleep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	interval := 10 * time.Second
+	tests := []struct {
+		name     string
+		deadline time.Time
+		want     time.Duration
+	}{
+		{"no deadline", time.Time{}, interval},
+		{"deadline after the interval", now.Add(time.Minute), interval},
+		{"deadline at the interval", now.Add(interval), interval},
+		{"deadline before the interval", now.Add(3 * time.Second), 3 * time.Second},
+		{"deadline passed", now.Add(-time.Second), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getCrashloopSleep(interval, tt.deadline, now); got != tt.want {
+				t.Errorf("getCrashloopSleep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
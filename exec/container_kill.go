@@ -0,0 +1,188 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	SignalFlag      = "signal"
+	ObserveTimeFlag = "observe-time"
+)
+
+const (
+	defaultKillSignal  = "SIGKILL"
+	defaultObserveTime = 5
+)
+
+type killActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewKillActionCommand() spec.ExpActionCommandSpec {
+	return &killActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: SignalFlag,
+					Desc: "The signal sent to the init process of the container by the container runtime, such as SIGKILL, SIGTERM, SIGQUIT or 9, default value is SIGKILL",
+				},
+				&spec.ExpFlag{
+					Name: ObserveTimeFlag,
+					Desc: "Seconds to observe the container state after the signal is sent, default value is 5",
+				},
+			},
+			ActionExecutor: &killActionExecutor{},
+			ActionExample: `# Send SIGKILL to the init process of the container id that is a76d53933d3f
+blade create docker container kill --container-id a76d53933d3f
+
+# Send SIGTERM to the init process of the container and observe the container state for 10 seconds
+blade create docker container kill --signal SIGTERM --observe-time 10 --container-id a76d53933d3f`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*killActionCommand) Name() string {
+	return "kill"
+}
+
+func (*killActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*killActionCommand) ShortDesc() string {
+	return "kill a container by signal"
+}
+
+func (k *killActionCommand) LongDesc() string {
+	if k.ActionLongDesc != "" {
+		return k.ActionLongDesc
+	}
+	return "send the signal to the init process of the container by the container runtime, the restart policy of the container takes effect"
+}
+
+type killActionExecutor struct {
+}
+
+// containerKillResult is the container state observed after the signal is sent
+type containerKillResult struct {
+	ContainerId       string `json:"containerId"`
+	Signal            string `json:"signal"`
+	RestartPolicy     string `json:"restartPolicy"`
+	MaximumRetryCount int    `json:"maximumRetryCount"`
+	Status            string `json:"status"`
+	ExitCode          int    `json:"exitCode"`
+	RestartCount      int    `json:"restartCount"`
+	Restarted         bool   `json:"restarted"`
+}
+
+func (*killActionExecutor) Name() string {
+	return "kill"
+}
+
+func (e *killActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *killActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		// the killed containers cannot be reverted, only the records are removed
+		removeExperimentRecords(uid, suid)
+		return spec.ReturnSuccess(uid)
+	}
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	signal := flags[SignalFlag]
+	if signal == "" {
+		signal = defaultKillSignal
+	}
+	observeTime, response := parseObserveTime(flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	// all the containers are observed until the same deadline
+	deadline := time.Now().Add(time.Duration(observeTime) * time.Second)
+	return execInContainersConcurrently(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		return killContainerAndObserve(uid, client, container, signal, deadline)
+	})
+}
+
+// parseObserveTime returns the seconds of the observe-time flag, or the defaultObserveTime if it is not specified
+func parseObserveTime(flags map[string]string) (int, *spec.Response) {
+	value := flags[ObserveTimeFlag]
+	if value == "" {
+		return defaultObserveTime, spec.Success()
+	}
+	observeTime, err := strconv.Atoi(value)
+	if err != nil || observeTime < 0 {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, ObserveTimeFlag, value, "it must be a non-negative integer")
+	}
+	return observeTime, spec.Success()
+}
+
+// killContainerAndObserve sends the signal to the container and returns the container state at the deadline
+func killContainerAndObserve(uid string, client *Client, container types.Container, signal string,
+	deadline time.Time) *spec.Response {
+	if container.State != "running" {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is %s", container.ID, container.State))
+		return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", container.State)
+	}
+	before, err := client.inspectContainer(container.ID)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	if err := client.killContainer(container.ID, signal); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerKill", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerKill", err)
+	}
+	time.Sleep(time.Until(deadline))
+	after, err := client.inspectContainer(container.ID)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	result := containerKillResult{
+		ContainerId:  container.ID,
+		Signal:       signal,
+		RestartCount: after.RestartCount,
+		Restarted:    after.RestartCount > before.RestartCount,
+	}
+	if after.HostConfig != nil {
+		result.RestartPolicy = after.HostConfig.RestartPolicy.Name
+		result.MaximumRetryCount = after.HostConfig.RestartPolicy.MaximumRetryCount
+	}
+	if after.State != nil {
+		result.Status = after.State.Status
+		result.ExitCode = after.State.ExitCode
+	}
+	return spec.ReturnSuccess(result)
+}
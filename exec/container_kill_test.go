@@ -0,0 +1,45 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import "testing"
+
+func TestParseObserveTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		success bool
+	}{
+		{"default", "", defaultObserveTime, true},
+		{"zero", "0", 0, true},
+		{"seconds", "30", 30, true},
+		{"negative", "-1", 0, false},
+		{"not an integer", "10s", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, response := parseObserveTime(map[string]string{ObserveTimeFlag: tt.value})
+			if response.Success != tt.success {
+				t.Fatalf("parseObserveTime() success = %v, want %v", response.Success, tt.success)
+			}
+			if got != tt.want {
+				t.Errorf("parseObserveTime() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
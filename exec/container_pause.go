@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+)
+
+type pauseActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewPauseActionCommand() spec.ExpActionCommandSpec {
+	return &pauseActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags:    []spec.ExpFlagSpec{},
+			ActionExecutor: &pauseActionExecutor{},
+			ActionExample: `# Freeze all processes in the container id that is a76d53933d3f
+blade create docker container pause --container-id a76d53933d3f
+
+# Freeze all processes in the container for 60 seconds
+blade create docker container pause --timeout 60 --container-id a76d53933d3f`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*pauseActionCommand) Name() string {
+	return "pause"
+}
+
+func (*pauseActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*pauseActionCommand) ShortDesc() string {
+	return "pause a container"
+}
+
+func (p *pauseActionCommand) LongDesc() string {
+	if p.ActionLongDesc != "" {
+		return p.ActionLongDesc
+	}
+	return "freeze all processes in the container by the cgroup freezer, the container is unpaused when the experiment is destroyed"
+}
+
+type pauseActionExecutor struct {
+}
+
+func (*pauseActionExecutor) Name() string {
+	return "pause"
+}
+
+func (e *pauseActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *pauseActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			containerJSON, err := client.inspectContainer(container.ID)
+			if err != nil {
+				util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+				return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+			}
+			if containerJSON.State == nil || !containerJSON.State.Paused {
+				// the container has been unpaused by the timeout or manually
+				return spec.ReturnSuccess(uid)
+			}
+			if err := client.unpauseContainer(container.ID); err != nil {
+				util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerUnpause", err))
+				return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerUnpause", err)
+			}
+			return spec.ReturnSuccess(uid)
+		})
+	}
+	timeout, response := parseTimeout(flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	pausedIds := make([]string, 0)
+	response = execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		if container.State != "running" {
+			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is %s", container.ID, container.State))
+			return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", container.State)
+		}
+		if err := client.pauseContainer(container.ID); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerPause", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerPause", err)
+		}
+		pausedIds = append(pausedIds, container.ID)
+		return spec.ReturnSuccess(uid)
+	})
+	// the destroy is scheduled only if any container is paused, and the paused containers are unpaused if it fails
+	if timeout > 0 && len(pausedIds) > 0 {
+		if scheduleResponse := scheduleDestroy(ctx, uid, timeout); !scheduleResponse.Success {
+			util.Errorf(uid, util.GetRunFuncName(), scheduleResponse.Err)
+			for _, containerId := range pausedIds {
+				if err := client.unpauseContainer(containerId); err != nil {
+					util.Warnf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerUnpause", err))
+				}
+			}
+			return scheduleResponse
+		}
+	}
+	return response
+}
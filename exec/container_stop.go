@@ -0,0 +1,167 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	StopTimeoutFlag = "stop-timeout"
+)
+
+// healthCheckTimeout is the max time to wait for the started container to become healthy
+const healthCheckTimeout = 30 * time.Second
+
+type stopActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewStopActionCommand() spec.ExpActionCommandSpec {
+	return &stopActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: StopTimeoutFlag,
+					Desc: "Seconds to wait for the container to stop before killing it, default value is 10",
+				},
+			},
+			ActionExecutor: &stopActionExecutor{},
+			ActionExample: `# Stop the container id that is a76d53933d3f, and start it again when destroying
+blade create docker container stop --container-id a76d53933d3f
+
+# Stop the container and kill it if it does not stop in 3 seconds
+blade create docker container stop --stop-timeout 3 --container-id a76d53933d3f`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*stopActionCommand) Name() string {
+	return "stop"
+}
+
+func (*stopActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*stopActionCommand) ShortDesc() string {
+	return "stop a container"
+}
+
+func (s *stopActionCommand) LongDesc() string {
+	if s.ActionLongDesc != "" {
+		return s.ActionLongDesc
+	}
+	return "stop a container, the container is started again when the experiment is destroyed"
+}
+
+type stopActionExecutor struct {
+}
+
+// containerStartResult is the state of the container started when destroying
+type containerStartResult struct {
+	ContainerId string `json:"containerId"`
+	Running     bool   `json:"running"`
+	Health      string `json:"health"`
+	Healthy     bool   `json:"healthy"`
+}
+
+func (*stopActionExecutor) Name() string {
+	return "stop"
+}
+
+func (e *stopActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *stopActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	if _, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			return startContainerAndCheckHealth(uid, client, container.ID)
+		})
+	}
+	timeout, response := parseStopTimeout(flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		if container.State != "running" {
+			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is %s", container.ID, container.State))
+			return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", container.State)
+		}
+		if err := client.stopContainer(container.ID, timeout); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerStop", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerStop", err)
+		}
+		return spec.ReturnSuccess(uid)
+	})
+}
+
+// parseStopTimeout returns the timeout of the stop-timeout flag, nil means the grace period of the daemon is used
+func parseStopTimeout(flags map[string]string) (*time.Duration, *spec.Response) {
+	value := flags[StopTimeoutFlag]
+	if value == "" {
+		return nil, spec.Success()
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, StopTimeoutFlag, value,
+			"it must be a non-negative integer")
+	}
+	timeout := time.Duration(seconds) * time.Second
+	return &timeout, spec.Success()
+}
+
+// startContainerAndCheckHealth starts the container and waits for the result of its health check
+func startContainerAndCheckHealth(uid string, client *Client, containerId string) *spec.Response {
+	if err := client.startContainer(containerId); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerStart", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerStart", err)
+	}
+	containerJSON, err := client.waitContainerHealthy(containerId, healthCheckTimeout)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	result := containerStartResult{
+		ContainerId: containerId,
+		Health:      types.NoHealthcheck,
+	}
+	if state := containerJSON.State; state != nil {
+		result.Running = state.Running
+		if state.Health != nil {
+			result.Health = state.Health.Status
+		}
+	}
+	result.Healthy = result.Running && (result.Health == types.NoHealthcheck || result.Health == types.Healthy)
+	return spec.ReturnSuccess(result)
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStopTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *time.Duration
+		success bool
+	}{
+		{"the grace period of the daemon", "", nil, true},
+		{"zero", "0", durationOf(0), true},
+		{"seconds", "30", durationOf(30 * time.Second), true},
+		{"negative", "-1", nil, false},
+		{"not an integer", "10s", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, response := parseStopTimeout(map[string]string{StopTimeoutFlag: tt.value})
+			if response.Success != tt.success {
+				t.Fatalf("parseStopTimeout() success = %v, want %v", response.Success, tt.success)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("parseStopTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func durationOf(duration time.Duration) *time.Duration {
+	return &duration
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestGetEndpointConfig(t *testing.T) {
+	containerId := "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name     string
+		settings *network.EndpointSettings
+		want     *network.EndpointSettings
+	}{
+		{
+			name:     "nil settings",
+			settings: nil,
+			want:     &network.EndpointSettings{},
+		},
+		{
+			name: "drop the operational data and the short id alias",
+			settings: &network.EndpointSettings{
+				IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "172.18.0.10"},
+				Links:      []string{"db:db"},
+				Aliases:    []string{"0123456789ab", "web"},
+				DriverOpts: map[string]string{"key": "value"},
+				NetworkID:  "network-id",
+				EndpointID: "endpoint-id",
+				IPAddress:  "172.18.0.10",
+				MacAddress: "02:42:ac:12:00:0a",
+			},
+			want: &network.EndpointSettings{
+				IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "172.18.0.10"},
+				Links:      []string{"db:db"},
+				Aliases:    []string{"web"},
+				DriverOpts: map[string]string{"key": "value"},
+			},
+		},
+		{
+			name:     "no aliases",
+			settings: &network.EndpointSettings{NetworkID: "network-id"},
+			want:     &network.EndpointSettings{Aliases: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getEndpointConfig(tt.settings, containerId); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getEndpointConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
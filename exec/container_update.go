@@ -0,0 +1,413 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	CpusFlag      = "cpus"
+	CpuSharesFlag = "cpu-shares"
+	MemoryFlag    = "memory"
+	PidsLimitFlag = "pids-limit"
+)
+
+const (
+	// defaultCPUPeriod is the default CFS period of docker in microseconds
+	defaultCPUPeriod = 100000
+	// defaultCPUShares is the default CPU shares of docker
+	defaultCPUShares = 1024
+	// recordKeyResources is the record key prefix of the resources of the container
+	recordKeyResources = "resources"
+)
+
+type updateActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewUpdateActionCommand() spec.ExpActionCommandSpec {
+	return &updateActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: CpusFlag,
+					Desc: "The number of CPUs the container can use, for example 0.5",
+				},
+				&spec.ExpFlag{
+					Name: CpuSharesFlag,
+					Desc: "CPU shares, the relative weight of the container",
+				},
+				&spec.ExpFlag{
+					Name: MemoryFlag,
+					Desc: "Memory limit of the container, unit is MB",
+				},
+				&spec.ExpFlag{
+					Name: PidsLimitFlag,
+					Desc: "The max number of processes in the container",
+				},
+			},
+			ActionExecutor: &updateActionExecutor{},
+			ActionExample: `# Limit the container to half a CPU
+blade create docker container update --cpus 0.5 --container-id a76d53933d3f
+
+# Limit the memory of the container to 256MB and the processes to 100
+blade create docker container update --memory 256 --pids-limit 100 --container-id a76d53933d3f`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*updateActionCommand) Name() string {
+	return "update"
+}
+
+func (*updateActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*updateActionCommand) ShortDesc() string {
+	return "update the resource limits of a container"
+}
+
+func (u *updateActionCommand) LongDesc() string {
+	if u.ActionLongDesc != "" {
+		return u.ActionLongDesc
+	}
+	return "lower the CPU, memory or pids limits of the running container, the original limits are restored when the experiment is destroyed"
+}
+
+type updateActionExecutor struct {
+}
+
+func (*updateActionExecutor) Name() string {
+	return "update"
+}
+
+func (e *updateActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *updateActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			return restoreContainerResources(uid, suid, client, container.ID)
+		})
+	}
+	limits, response := parseResourcesLimits(flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	if limits.pidsLimit > 0 {
+		if err := client.checkAPIVersion(pidsLimitAPIVersion, "update the pids limit"); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("CheckAPIVersion", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "CheckAPIVersion", err)
+		}
+	}
+	return execInContainers(uid, ctx, client, model, func(ctn types.Container) *spec.Response {
+		return updateContainerResources(uid, client, ctn.ID, func(original container.Resources) (container.Resources, *spec.Response) {
+			return applyResourcesLimits(original, limits), spec.Success()
+		})
+	})
+}
+
+// resourcesLimits is the resource limits specified by the flags, 0 means not specified
+type resourcesLimits struct {
+	cpus      float64
+	cpuShares int64
+	memory    int64
+	pidsLimit int64
+}
+
+// parseResourcesLimits returns the resource limits from the flags, at least one limit must be specified
+func parseResourcesLimits(flags map[string]string) (resourcesLimits, *spec.Response) {
+	limits := resourcesLimits{}
+	if value := flags[CpusFlag]; value != "" {
+		cpus, err := strconv.ParseFloat(value, 64)
+		if err != nil || cpus <= 0 {
+			return limits, spec.ResponseFailWithFlags(spec.ParameterIllegal, CpusFlag, value, "it must be a positive number")
+		}
+		limits.cpus = cpus
+	}
+	var response *spec.Response
+	if limits.cpuShares, response = parsePositiveInt64(flags, CpuSharesFlag); !response.Success {
+		return limits, response
+	}
+	if limits.memory, response = parsePositiveInt64(flags, MemoryFlag); !response.Success {
+		return limits, response
+	}
+	if limits.pidsLimit, response = parsePositiveInt64(flags, PidsLimitFlag); !response.Success {
+		return limits, response
+	}
+	if limits == (resourcesLimits{}) {
+		tips := fmt.Sprintf("%s, %s, %s or %s", CpusFlag, CpuSharesFlag, MemoryFlag, PidsLimitFlag)
+		return limits, spec.ResponseFailWithFlags(spec.ParameterLess, tips)
+	}
+	return limits, spec.Success()
+}
+
+// parsePositiveInt64 returns the positive integer value of the flag, 0 if the flag is not specified
+func parsePositiveInt64(flags map[string]string, name string) (int64, *spec.Response) {
+	value := flags[name]
+	if value == "" {
+		return 0, spec.Success()
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, name, value, "it must be a positive integer")
+	}
+	return v, spec.Success()
+}
+
+// applyResourcesLimits returns the resources to update by the limits
+func applyResourcesLimits(original container.Resources, limits resourcesLimits) container.Resources {
+	updated := container.Resources{}
+	if limits.cpus > 0 {
+		if original.NanoCPUs > 0 {
+			// the nano cpus and the cfs quota cannot be set at the same time
+			updated.NanoCPUs = int64(limits.cpus * 1e9)
+		} else {
+			period := original.CPUPeriod
+			if period == 0 {
+				period = defaultCPUPeriod
+			}
+			updated.CPUPeriod = period
+			updated.CPUQuota = int64(limits.cpus * float64(period))
+		}
+	}
+	updated.CPUShares = limits.cpuShares
+	if limits.memory > 0 {
+		updated.Memory = limits.memory * 1024 * 1024
+		if original.MemorySwap > 0 && original.MemorySwap < updated.Memory {
+			updated.MemorySwap = updated.Memory
+		}
+	}
+	updated.PidsLimit = limits.pidsLimit
+	return updated
+}
+
+// resourcesRecord is the resources of the container before and after the update
+type resourcesRecord struct {
+	Original container.Resources `json:"original"`
+	Updated  container.Resources `json:"updated"`
+}
+
+// resourcesResult is the updated resources of the container
+type resourcesResult struct {
+	ContainerId string           `json:"containerId"`
+	Original    changedResources `json:"original"`
+	Updated     changedResources `json:"updated"`
+}
+
+// updateContainerResources records the original resources of the container, and updates the container with the
+// resources returned by the build function. The restoreContainerResources function restores the original resources.
+func updateContainerResources(uid string, client *Client, containerId string,
+	build func(original container.Resources) (container.Resources, *spec.Response)) *spec.Response {
+	containerJSON, err := client.inspectContainer(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	if containerJSON.State == nil || !containerJSON.State.Running || containerJSON.HostConfig == nil {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is not running", containerId))
+		return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", "not running")
+	}
+	original := containerJSON.HostConfig.Resources
+	updated, response := build(original)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	recordKey := fmt.Sprintf("%s-%s", recordKeyResources, containerJSON.ID)
+	if err := saveRecord(uid, recordKey, resourcesRecord{Original: original, Updated: updated}); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SaveResources", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveResources", err)
+	}
+	if err := client.updateContainer(containerJSON.ID, updated); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerUpdate", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerUpdate", err)
+	}
+	// the daemon may drop the fields it does not support without any error, so check the resources are applied
+	containerId = containerJSON.ID
+	containerJSON, err = client.inspectContainer(containerId)
+	if err == nil && containerJSON.HostConfig == nil {
+		err = fmt.Errorf("the host config of the container %s is not found", containerId)
+	}
+	if err != nil {
+		restoreContainerResources(uid, uid, client, containerId)
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	if unapplied := getUnappliedResources(containerJSON.HostConfig.Resources, updated); len(unapplied) > 0 {
+		restoreContainerResources(uid, uid, client, containerJSON.ID)
+		msg := fmt.Sprintf("the %s of the container is not applied by the docker daemon", strings.Join(unapplied, ", "))
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerUpdate", msg))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerUpdate", msg)
+	}
+	return spec.ReturnSuccess(resourcesResult{
+		ContainerId: containerJSON.ID,
+		Original:    getChangedResources(original, updated),
+		Updated:     getChangedResources(updated, updated),
+	})
+}
+
+// getUnappliedResources returns the names of the updated resources which are not the same as the applied resources
+func getUnappliedResources(applied, updated container.Resources) []string {
+	unapplied := make([]string, 0)
+	check := func(name string, applied, updated int64) {
+		if updated != 0 && applied != updated {
+			unapplied = append(unapplied, name)
+		}
+	}
+	check("nano cpus", applied.NanoCPUs, updated.NanoCPUs)
+	check("cpu period", applied.CPUPeriod, updated.CPUPeriod)
+	check("cpu quota", applied.CPUQuota, updated.CPUQuota)
+	check("cpu shares", applied.CPUShares, updated.CPUShares)
+	check("memory", applied.Memory, updated.Memory)
+	check("pids limit", applied.PidsLimit, updated.PidsLimit)
+	return unapplied
+}
+
+// restoreContainerResources restores the original resources of the container recorded by updateContainerResources
+func restoreContainerResources(uid, suid string, client *Client, containerId string) *spec.Response {
+	recordKey := fmt.Sprintf("%s-%s", recordKeyResources, containerId)
+	record := resourcesRecord{}
+	exists, err := loadRecord(suid, recordKey, &record)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadResources", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadResources", err)
+	}
+	if !exists {
+		return spec.ReturnSuccess(uid)
+	}
+	restored, err := getRestoredResources(client, record)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetRestoredResources", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetRestoredResources", err)
+	}
+	if err := client.updateContainer(containerId, restored); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerUpdate", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerUpdate", err)
+	}
+	if err := removeRecord(suid, recordKey); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the resources record of %s failed, %v", suid, err))
+	}
+	return spec.ReturnSuccess(resourcesResult{
+		ContainerId: containerId,
+		Original:    getChangedResources(record.Original, record.Updated),
+		Updated:     getChangedResources(record.Updated, record.Updated),
+	})
+}
+
+// getRestoredResources returns the original values of the updated resources. The zero value means no change
+// in the update api, so the unlimited value is used instead if there is no limit originally.
+func getRestoredResources(client *Client, record resourcesRecord) (container.Resources, error) {
+	original, updated := record.Original, record.Updated
+	restored := container.Resources{}
+	if updated.NanoCPUs != 0 {
+		restored.NanoCPUs = original.NanoCPUs
+	}
+	if updated.CPUPeriod != 0 {
+		restored.CPUPeriod = original.CPUPeriod
+		if restored.CPUPeriod == 0 {
+			restored.CPUPeriod = defaultCPUPeriod
+		}
+	}
+	if updated.CPUQuota != 0 {
+		restored.CPUQuota = original.CPUQuota
+		if restored.CPUQuota == 0 {
+			restored.CPUQuota = -1
+		}
+	}
+	if updated.CPUShares != 0 {
+		restored.CPUShares = original.CPUShares
+		if restored.CPUShares == 0 {
+			restored.CPUShares = defaultCPUShares
+		}
+	}
+	if updated.Memory != 0 {
+		restored.Memory = original.Memory
+		restored.MemorySwap = original.MemorySwap
+		if restored.Memory == 0 {
+			// the memory limit cannot be removed, so use the host memory instead
+			memTotal, err := client.getHostMemTotal()
+			if err != nil {
+				return restored, err
+			}
+			restored.Memory = memTotal
+			restored.MemorySwap = -1
+		}
+	}
+	if updated.PidsLimit != 0 {
+		restored.PidsLimit = original.PidsLimit
+		if restored.PidsLimit == 0 {
+			restored.PidsLimit = -1
+		}
+	}
+	return restored, nil
+}
+
+// changedResources is the resources changed by the experiment
+type changedResources struct {
+	NanoCPUs   int64 `json:"nanoCpus,omitempty"`
+	CPUPeriod  int64 `json:"cpuPeriod,omitempty"`
+	CPUQuota   int64 `json:"cpuQuota,omitempty"`
+	CPUShares  int64 `json:"cpuShares,omitempty"`
+	Memory     int64 `json:"memory,omitempty"`
+	MemorySwap int64 `json:"memorySwap,omitempty"`
+	PidsLimit  int64 `json:"pidsLimit,omitempty"`
+}
+
+// getChangedResources returns the values of the resources which are changed in the updated resources
+func getChangedResources(resources, updated container.Resources) changedResources {
+	changed := changedResources{}
+	if updated.NanoCPUs != 0 {
+		changed.NanoCPUs = resources.NanoCPUs
+	}
+	if updated.CPUPeriod != 0 {
+		changed.CPUPeriod = resources.CPUPeriod
+	}
+	if updated.CPUQuota != 0 {
+		changed.CPUQuota = resources.CPUQuota
+	}
+	if updated.CPUShares != 0 {
+		changed.CPUShares = resources.CPUShares
+	}
+	if updated.Memory != 0 {
+		changed.Memory = resources.Memory
+		changed.MemorySwap = resources.MemorySwap
+	}
+	if updated.PidsLimit != 0 {
+		changed.PidsLimit = resources.PidsLimit
+	}
+	return changed
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestApplyResourcesLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		original container.Resources
+		limits   resourcesLimits
+		want     container.Resources
+	}{
+		{
+			name:     "cpus by the nano cpus",
+			original: container.Resources{NanoCPUs: 2e9},
+			limits:   resourcesLimits{cpus: 0.5},
+			want:     container.Resources{NanoCPUs: 5e8},
+		},
+		{
+			name:     "cpus by the default cfs period",
+			original: container.Resources{},
+			limits:   resourcesLimits{cpus: 0.5},
+			want:     container.Resources{CPUPeriod: defaultCPUPeriod, CPUQuota: defaultCPUPeriod / 2},
+		},
+		{
+			name:     "cpus by the original cfs period",
+			original: container.Resources{CPUPeriod: 50000, CPUQuota: 100000},
+			limits:   resourcesLimits{cpus: 1},
+			want:     container.Resources{CPUPeriod: 50000, CPUQuota: 50000},
+		},
+		{
+			name:     "memory keeps the swap",
+			original: container.Resources{Memory: 512 * 1024 * 1024},
+			limits:   resourcesLimits{memory: 128},
+			want:     container.Resources{Memory: 128 * 1024 * 1024},
+		},
+		{
+			name:     "memory raises the swap lower than the memory",
+			original: container.Resources{Memory: 64 * 1024 * 1024, MemorySwap: 64 * 1024 * 1024},
+			limits:   resourcesLimits{memory: 128},
+			want:     container.Resources{Memory: 128 * 1024 * 1024, MemorySwap: 128 * 1024 * 1024},
+		},
+		{
+			name:     "cpu shares and pids limit",
+			original: container.Resources{},
+			limits:   resourcesLimits{cpuShares: 256, pidsLimit: 100},
+			want:     container.Resources{CPUShares: 256, PidsLimit: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyResourcesLimits(tt.original, tt.limits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyResourcesLimits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRestoredResources(t *testing.T) {
+	tests := []struct {
+		name   string
+		record resourcesRecord
+		want   container.Resources
+	}{
+		{
+			name: "nano cpus",
+			record: resourcesRecord{
+				Original: container.Resources{NanoCPUs: 2e9},
+				Updated:  container.Resources{NanoCPUs: 5e8},
+			},
+			want: container.Resources{NanoCPUs: 2e9},
+		},
+		{
+			name: "cfs quota without the original limit",
+			record: resourcesRecord{
+				Original: container.Resources{},
+				Updated:  container.Resources{CPUPeriod: defaultCPUPeriod, CPUQuota: defaultCPUPeriod / 2},
+			},
+			want: container.Resources{CPUPeriod: defaultCPUPeriod, CPUQuota: -1},
+		},
+		{
+			name: "cpu shares without the original shares",
+			record: resourcesRecord{
+				Original: container.Resources{},
+				Updated:  container.Resources{CPUShares: 256},
+			},
+			want: container.Resources{CPUShares: defaultCPUShares},
+		},
+		{
+			name: "memory and swap",
+			record: resourcesRecord{
+				Original: container.Resources{Memory: 512 * 1024 * 1024, MemorySwap: -1},
+				Updated:  container.Resources{Memory: 128 * 1024 * 1024},
+			},
+			want: container.Resources{Memory: 512 * 1024 * 1024, MemorySwap: -1},
+		},
+		{
+			name: "pids limit without the original limit",
+			record: resourcesRecord{
+				Original: container.Resources{},
+				Updated:  container.Resources{PidsLimit: 100},
+			},
+			want: container.Resources{PidsLimit: -1},
+		},
+		{
+			name: "only the updated resources are restored",
+			record: resourcesRecord{
+				Original: container.Resources{NanoCPUs: 2e9, Memory: 512 * 1024 * 1024, PidsLimit: 200},
+				Updated:  container.Resources{PidsLimit: 100},
+			},
+			want: container.Resources{PidsLimit: 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the client is only used to get the host memory if the memory was not limited
+			got, err := getRestoredResources(nil, tt.record)
+			if err != nil {
+				t.Fatalf("getRestoredResources() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRestoredResources() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
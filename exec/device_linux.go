@@ -0,0 +1,77 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// getBlockDevice returns the major:minor number of the whole disk which the file is stored on, for example 8:0
+func getBlockDevice(file string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(file, &stat); err != nil {
+		return "", err
+	}
+	disk, err := getDiskNumber(getDeviceNumber(uint64(stat.Dev)))
+	if err != nil {
+		return "", fmt.Errorf("%s is not stored on a block device, %v", file, err)
+	}
+	return disk, nil
+}
+
+// getDeviceFileNumber returns the major:minor number of the whole disk of the block device file, for example /dev/sda,
+// the disk of the partition is returned if the file is a partition, such as /dev/sda1
+func getDeviceFileNumber(device string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(device, &stat); err != nil {
+		return "", err
+	}
+	if stat.Mode&syscall.S_IFMT != syscall.S_IFBLK {
+		return "", fmt.Errorf("%s is not a block device", device)
+	}
+	return getDiskNumber(getDeviceNumber(uint64(stat.Rdev)))
+}
+
+// getDiskNumber returns the major:minor number of the disk of the block device, the blkio throttle does not support
+// the partition, so the disk of the partition is used
+func getDiskNumber(deviceNumber string) (string, error) {
+	sysPath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%s", deviceNumber))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path.Join(sysPath, "partition")); err == nil {
+		sysPath = path.Dir(sysPath)
+	}
+	bytes, err := ioutil.ReadFile(path.Join(sysPath, "dev"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+// getDeviceNumber returns the major:minor format of the device number
+func getDeviceNumber(dev uint64) string {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	return fmt.Sprintf("%d:%d", major, minor)
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// getBlockDevice returns the major:minor number of the whole disk which the file is stored on, only supported on linux
+func getBlockDevice(file string) (string, error) {
+	return "", fmt.Errorf("resolving the block device is not supported on %s", runtime.GOOS)
+}
+
+// getDeviceFileNumber returns the major:minor number of the block device file, only supported on linux
+func getDeviceFileNumber(device string) (string, error) {
+	return "", fmt.Errorf("resolving the block device is not supported on %s", runtime.GOOS)
+}
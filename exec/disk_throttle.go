@@ -0,0 +1,429 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	ReadBpsFlag   = "read-bps"
+	WriteBpsFlag  = "write-bps"
+	ReadIOpsFlag  = "read-iops"
+	WriteIOpsFlag = "write-iops"
+	DeviceFlag    = "device"
+	PathFlag      = "path"
+)
+
+type throttleActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewThrottleActionCommand() spec.ExpActionCommandSpec {
+	return &throttleActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: ReadBpsFlag,
+					Desc: "Limit the read rate of the device, unit is byte per second, the k, m and g suffixes are supported, for example 1m",
+				},
+				&spec.ExpFlag{
+					Name: WriteBpsFlag,
+					Desc: "Limit the write rate of the device, unit is byte per second, the k, m and g suffixes are supported, for example 1m",
+				},
+				&spec.ExpFlag{
+					Name: ReadIOpsFlag,
+					Desc: "Limit the read IO per second of the device",
+				},
+				&spec.ExpFlag{
+					Name: WriteIOpsFlag,
+					Desc: "Limit the write IO per second of the device",
+				},
+				&spec.ExpFlag{
+					Name: DeviceFlag,
+					Desc: "The block device on the host to throttle, for example /dev/sda, resolved from the path flag if not specified",
+				},
+				&spec.ExpFlag{
+					Name: PathFlag,
+					Desc: "The path in the container whose backing device is throttled, default value is the root filesystem of the container",
+				},
+			},
+			ActionExecutor: &throttleActionExecutor{},
+			ActionExample: `# Limit the read and write rate of the container root filesystem to 1MB/s
+blade create docker disk throttle --read-bps 1m --write-bps 1m --container-id ee54f1e61c08
+
+# Limit the write IOPS of the volume mounted on /data to 100
+blade create docker disk throttle --write-iops 100 --path /data --container-id ee54f1e61c08`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*throttleActionCommand) Name() string {
+	return "throttle"
+}
+
+func (*throttleActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*throttleActionCommand) ShortDesc() string {
+	return "throttle the disk IO of a container"
+}
+
+func (t *throttleActionCommand) LongDesc() string {
+	if t.ActionLongDesc != "" {
+		return t.ActionLongDesc
+	}
+	return "slow down the disk IO of the container by writing the blkio throttle to the cgroup of the container, only the docker daemon on the local host is supported, the original throttle is restored when the experiment is destroyed"
+}
+
+type throttleActionExecutor struct {
+}
+
+// throttleLimits is the blkio limits specified by the flags, 0 means not specified
+type throttleLimits struct {
+	readBps   uint64
+	writeBps  uint64
+	readIOps  uint64
+	writeIOps uint64
+}
+
+// recordKeyThrottle is the record key prefix of the blkio throttle of the container
+const recordKeyThrottle = "throttle"
+
+// throttleKey is the name of the limit in the blkio throttle files of cgroup v1 and in the io.max file of cgroup v2
+type throttleKey struct {
+	v1File string
+	v2Key  string
+}
+
+// throttleKeys are the keys of the read bps, write bps, read iops and write iops limits
+var throttleKeys = []throttleKey{
+	{v1File: "blkio.throttle.read_bps_device", v2Key: "rbps"},
+	{v1File: "blkio.throttle.write_bps_device", v2Key: "wbps"},
+	{v1File: "blkio.throttle.read_iops_device", v2Key: "riops"},
+	{v1File: "blkio.throttle.write_iops_device", v2Key: "wiops"},
+}
+
+// throttleRecord is the blkio throttle of the device in the cgroup of the container before and after the update,
+// the keys are the blkio throttle files for cgroup v1 and the io.max keys for cgroup v2
+type throttleRecord struct {
+	ContainerId string            `json:"containerId"`
+	CgroupPath  string            `json:"cgroupPath"`
+	CgroupV2    bool              `json:"cgroupV2"`
+	Device      string            `json:"device"`
+	Original    map[string]string `json:"original"`
+	Updated     map[string]string `json:"updated"`
+}
+
+func (*throttleActionExecutor) Name() string {
+	return "throttle"
+}
+
+func (e *throttleActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *throttleActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	// the docker daemon ignores the blkio limits in the update api, so the cgroup files of the container are written,
+	// which requires the docker daemon on the same host
+	if !client.isLocalDaemon() {
+		util.Errorf(uid, util.GetRunFuncName(), "the disk throttle does not support the remote docker daemon")
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, EndpointFlag.Name, flags[EndpointFlag.Name],
+			"the disk throttle only supports the docker daemon on the local host")
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			return e.restore(uid, suid, container.ID)
+		})
+	}
+	limits, response := parseThrottleLimits(flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	return execInContainers(uid, ctx, client, model, func(ctn types.Container) *spec.Response {
+		return e.throttle(uid, client, ctn.ID, flags[DeviceFlag], flags[PathFlag], limits)
+	})
+}
+
+// throttle records the original blkio throttle of the device and writes the limits to the cgroup of the container
+func (e *throttleActionExecutor) throttle(uid string, client *Client, containerId, device, containerPath string,
+	limits throttleLimits) *spec.Response {
+	containerJSON, err := client.inspectContainer(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	if containerJSON.State == nil || !containerJSON.State.Running || containerJSON.State.Pid == 0 {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is not running", containerId))
+		return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", "not running")
+	}
+	if device != "" {
+		device, err = getDeviceFileNumber(device)
+	} else {
+		device, err = e.getBackingDevice(client, containerJSON, containerPath)
+	}
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetBackingDevice", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetBackingDevice", err)
+	}
+	cgroupPath, err := getCgroupPath(containerJSON.State.Pid, "blkio")
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetContainerCgroup", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetContainerCgroup", err)
+	}
+	record := throttleRecord{
+		ContainerId: containerJSON.ID,
+		CgroupPath:  cgroupPath,
+		CgroupV2:    isCgroupV2(),
+		Device:      device,
+		Updated:     getThrottleValues(limits, isCgroupV2()),
+	}
+	record.Original, err = readThrottle(record.CgroupPath, record.CgroupV2, device)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ReadBlkioThrottle", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ReadBlkioThrottle", err)
+	}
+	recordKey := fmt.Sprintf("%s-%s", recordKeyThrottle, containerJSON.ID)
+	if err := saveRecord(uid, recordKey, record); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SaveBlkioThrottle", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveBlkioThrottle", err)
+	}
+	if err := writeThrottle(record.CgroupPath, record.CgroupV2, device, record.Updated); err != nil {
+		writeThrottle(record.CgroupPath, record.CgroupV2, device, record.Original)
+		removeRecord(uid, recordKey)
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("WriteBlkioThrottle", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "WriteBlkioThrottle", err)
+	}
+	return spec.ReturnSuccess(record)
+}
+
+// restore writes the original blkio throttle recorded by the throttle function, the cgroup is removed with the
+// container, so nothing is restored if the cgroup does not exist
+func (e *throttleActionExecutor) restore(uid, suid string, containerId string) *spec.Response {
+	recordKey := fmt.Sprintf("%s-%s", recordKeyThrottle, containerId)
+	record := throttleRecord{}
+	exists, err := loadRecord(suid, recordKey, &record)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadBlkioThrottle", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadBlkioThrottle", err)
+	}
+	if !exists {
+		return spec.ReturnSuccess(uid)
+	}
+	if util.IsExist(record.CgroupPath) {
+		if err := writeThrottle(record.CgroupPath, record.CgroupV2, record.Device, record.Original); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("WriteBlkioThrottle", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "WriteBlkioThrottle", err)
+		}
+	}
+	if err := removeRecord(suid, recordKey); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the throttle record of %s failed, %v", suid, err))
+	}
+	return spec.ReturnSuccess(record)
+}
+
+// getThrottleValues returns the values of the specified limits by the keys of the cgroup version
+func getThrottleValues(limits throttleLimits, v2 bool) map[string]string {
+	values := make(map[string]string)
+	for i, rate := range []uint64{limits.readBps, limits.writeBps, limits.readIOps, limits.writeIOps} {
+		if rate == 0 {
+			continue
+		}
+		if v2 {
+			values[throttleKeys[i].v2Key] = strconv.FormatUint(rate, 10)
+		} else {
+			values[throttleKeys[i].v1File] = strconv.FormatUint(rate, 10)
+		}
+	}
+	return values
+}
+
+// readThrottle returns the current blkio throttle of the device in the cgroup, the unlimited value is 0 for cgroup v1
+// and max for cgroup v2
+func readThrottle(cgroupPath string, v2 bool, device string) (map[string]string, error) {
+	values := make(map[string]string)
+	if v2 {
+		bytes, err := ioutil.ReadFile(path.Join(cgroupPath, "io.max"))
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range throttleKeys {
+			values[key.v2Key] = "max"
+		}
+		// the line format is major:minor rbps=max wbps=max riops=max wiops=max
+		for _, line := range strings.Split(string(bytes), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || fields[0] != device {
+				continue
+			}
+			for _, field := range fields[1:] {
+				if kv := strings.SplitN(field, "=", 2); len(kv) == 2 {
+					values[kv[0]] = kv[1]
+				}
+			}
+		}
+		return values, nil
+	}
+	for _, key := range throttleKeys {
+		bytes, err := ioutil.ReadFile(path.Join(cgroupPath, key.v1File))
+		if err != nil {
+			return nil, err
+		}
+		values[key.v1File] = "0"
+		// the line format is major:minor rate
+		for _, line := range strings.Split(string(bytes), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == device {
+				values[key.v1File] = fields[1]
+			}
+		}
+	}
+	return values, nil
+}
+
+// writeThrottle writes the blkio throttle of the device to the cgroup
+func writeThrottle(cgroupPath string, v2 bool, device string, values map[string]string) error {
+	if v2 {
+		limits := []string{device}
+		for _, key := range throttleKeys {
+			if value, ok := values[key.v2Key]; ok {
+				limits = append(limits, fmt.Sprintf("%s=%s", key.v2Key, value))
+			}
+		}
+		return ioutil.WriteFile(path.Join(cgroupPath, "io.max"), []byte(strings.Join(limits, " ")), 0644)
+	}
+	for _, key := range throttleKeys {
+		value, ok := values[key.v1File]
+		if !ok {
+			continue
+		}
+		err := ioutil.WriteFile(path.Join(cgroupPath, key.v1File), []byte(fmt.Sprintf("%s %s", device, value)), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getBackingDevice returns the major:minor number of the block device which stores the path in the container
+func (e *throttleActionExecutor) getBackingDevice(client *Client, containerJSON types.ContainerJSON,
+	containerPath string) (string, error) {
+	if containerPath != "" {
+		// use the source of the mount point which contains the path
+		var source, destination string
+		for _, m := range containerJSON.Mounts {
+			if isSubPath(containerPath, m.Destination) && len(m.Destination) > len(destination) {
+				source, destination = m.Source, m.Destination
+			}
+		}
+		if source != "" {
+			return getBlockDevice(source)
+		}
+	}
+	graphDriver := containerJSON.GraphDriver
+	if graphDriver.Name == "devicemapper" && graphDriver.Data["DeviceName"] != "" {
+		return getDeviceFileNumber(path.Join("/dev/mapper", graphDriver.Data["DeviceName"]))
+	}
+	for _, key := range []string{"UpperDir", "MergedDir"} {
+		if dir := graphDriver.Data[key]; dir != "" {
+			return getBlockDevice(dir)
+		}
+	}
+	rootDir, err := client.getDockerRootDir()
+	if err != nil {
+		return "", err
+	}
+	return getBlockDevice(rootDir)
+}
+
+// isSubPath returns true if the file is the dir or in the dir
+func isSubPath(file, dir string) bool {
+	file, dir = path.Clean(file), path.Clean(dir)
+	return file == dir || dir == "/" || strings.HasPrefix(file, dir+"/")
+}
+
+// parseThrottleLimits returns the blkio limits from the flags, at least one limit must be specified
+func parseThrottleLimits(flags map[string]string) (throttleLimits, *spec.Response) {
+	limits := throttleLimits{}
+	var err error
+	if value := flags[ReadBpsFlag]; value != "" {
+		if limits.readBps, err = parseByteSize(value); err != nil || limits.readBps == 0 {
+			return limits, spec.ResponseFailWithFlags(spec.ParameterIllegal, ReadBpsFlag, value, "it must be a positive size")
+		}
+	}
+	if value := flags[WriteBpsFlag]; value != "" {
+		if limits.writeBps, err = parseByteSize(value); err != nil || limits.writeBps == 0 {
+			return limits, spec.ResponseFailWithFlags(spec.ParameterIllegal, WriteBpsFlag, value, "it must be a positive size")
+		}
+	}
+	if value := flags[ReadIOpsFlag]; value != "" {
+		if limits.readIOps, err = strconv.ParseUint(value, 10, 64); err != nil || limits.readIOps == 0 {
+			return limits, spec.ResponseFailWithFlags(spec.ParameterIllegal, ReadIOpsFlag, value, "it must be a positive integer")
+		}
+	}
+	if value := flags[WriteIOpsFlag]; value != "" {
+		if limits.writeIOps, err = strconv.ParseUint(value, 10, 64); err != nil || limits.writeIOps == 0 {
+			return limits, spec.ResponseFailWithFlags(spec.ParameterIllegal, WriteIOpsFlag, value, "it must be a positive integer")
+		}
+	}
+	if limits == (throttleLimits{}) {
+		tips := fmt.Sprintf("%s, %s, %s or %s", ReadBpsFlag, WriteBpsFlag, ReadIOpsFlag, WriteIOpsFlag)
+		return limits, spec.ResponseFailWithFlags(spec.ParameterLess, tips)
+	}
+	return limits, spec.Success()
+}
+
+// parseByteSize parses the size with the optional k, m or g suffix to bytes
+func parseByteSize(value string) (uint64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "b")
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(value, "k"):
+		multiplier = 1024
+	case strings.HasSuffix(value, "m"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(value, "g"):
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * multiplier, nil
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint64
+		wantErr bool
+	}{
+		{value: "1024", want: 1024},
+		{value: "10k", want: 10 * 1024},
+		{value: "10KB", want: 10 * 1024},
+		{value: " 20m ", want: 20 * 1024 * 1024},
+		{value: "1g", want: 1024 * 1024 * 1024},
+		{value: "512b", want: 512},
+		{value: "", wantErr: true},
+		{value: "m", wantErr: true},
+		{value: "-1m", wantErr: true},
+		{value: "1.5m", wantErr: true},
+		{value: "10t", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseByteSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// newThrottleCgroup returns a temporary cgroup directory with the throttle files of the cgroup version
+func newThrottleCgroup(t *testing.T, v2 bool, content string) string {
+	dir, err := ioutil.TempDir("", "blkio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{"io.max"}
+	if !v2 {
+		files = make([]string, 0, len(throttleKeys))
+		for _, key := range throttleKeys {
+			files = append(files, key.v1File)
+		}
+	}
+	for _, file := range files {
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		v2      bool
+		content string
+		want    map[string]string
+	}{
+		{
+			name:    "cgroup v1 unlimited",
+			content: "8:16 1048576\n",
+			want: map[string]string{
+				"blkio.throttle.read_bps_device": "0", "blkio.throttle.write_bps_device": "0",
+				"blkio.throttle.read_iops_device": "0", "blkio.throttle.write_iops_device": "0",
+			},
+		},
+		{
+			name:    "cgroup v1 limited",
+			content: "8:16 1\n8:0 1048576\n",
+			want: map[string]string{
+				"blkio.throttle.read_bps_device": "1048576", "blkio.throttle.write_bps_device": "1048576",
+				"blkio.throttle.read_iops_device": "1048576", "blkio.throttle.write_iops_device": "1048576",
+			},
+		},
+		{
+			name:    "cgroup v2 unlimited",
+			v2:      true,
+			content: "",
+			want:    map[string]string{"rbps": "max", "wbps": "max", "riops": "max", "wiops": "max"},
+		},
+		{
+			name:    "cgroup v2 limited",
+			v2:      true,
+			content: "8:16 rbps=1 wbps=max riops=max wiops=max\n8:0 rbps=1048576 wbps=max riops=max wiops=100\n",
+			want:    map[string]string{"rbps": "1048576", "wbps": "max", "riops": "max", "wiops": "100"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newThrottleCgroup(t, tt.v2, tt.content)
+			defer os.RemoveAll(dir)
+			got, err := readThrottle(dir, tt.v2, "8:0")
+			if err != nil {
+				t.Fatalf("readThrottle() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readThrottle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := readThrottle(os.TempDir()+"/not-exist", true, "8:0"); err == nil {
+		t.Errorf("readThrottle() of the missing cgroup returns no error")
+	}
+}
+
+func TestWriteThrottle(t *testing.T) {
+	tests := []struct {
+		name   string
+		v2     bool
+		limits throttleLimits
+		want   map[string]string
+	}{
+		{
+			name:   "cgroup v1",
+			limits: throttleLimits{readBps: 1048576, writeIOps: 100},
+			want: map[string]string{
+				"blkio.throttle.read_bps_device":  "8:0 1048576",
+				"blkio.throttle.write_bps_device": "",
+				"blkio.throttle.read_iops_device": "",
+				// the files of the limits not specified are not written
+				"blkio.throttle.write_iops_device": "8:0 100",
+			},
+		},
+		{
+			name:   "cgroup v2",
+			v2:     true,
+			limits: throttleLimits{readBps: 1048576, writeIOps: 100},
+			want:   map[string]string{"io.max": "8:0 rbps=1048576 wiops=100"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newThrottleCgroup(t, tt.v2, "")
+			defer os.RemoveAll(dir)
+			if err := writeThrottle(dir, tt.v2, "8:0", getThrottleValues(tt.limits, tt.v2)); err != nil {
+				t.Fatalf("writeThrottle() error = %v", err)
+			}
+			for file, want := range tt.want {
+				bytes, err := ioutil.ReadFile(path.Join(dir, file))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(bytes) != want {
+					t.Errorf("writeThrottle() writes %q to %s, want %q", string(bytes), file, want)
+				}
+			}
+		})
+	}
+}
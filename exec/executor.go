@@ -19,8 +19,16 @@ package exec
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
 	"github.com/docker/docker/api/types"
@@ -49,6 +57,55 @@ var commonFunc = func(uid string, ctx context.Context, model *spec.ExpModel) str
 	return fmt.Sprintf("%s create %s %s %s --uid %s", BladeBin, model.Target, model.ActionName, matchers, uid)
 }
 
+// commonArgsFunc is the argv of the command created by the commonFunc, the flag values are passed as they are, so the
+// blade tool can be executed without the shell
+var commonArgsFunc = func(uid string, ctx context.Context, model *spec.ExpModel) []string {
+	_, isDestroy := spec.IsDestroy(ctx)
+	args := []string{BladeBin, "create", model.Target, model.ActionName}
+	if isDestroy {
+		args[1] = "destroy"
+	}
+	excludeKeys := GetAllDockerFlagNames()
+	names := make([]string, 0, len(model.ActionFlags))
+	for name, value := range model.ActionFlags {
+		if _, ok := excludeKeys[name]; ok || value == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("--%s=%s", name, model.ActionFlags[name]))
+	}
+	if !isDestroy {
+		args = append(args, "--uid", uid)
+	}
+	return args
+}
+
+// execWarningResult is the response result with the warnings written to the stderr by the succeeded command
+type execWarningResult struct {
+	Warnings []string    `json:"warnings"`
+	Result   interface{} `json:"result"`
+}
+
+// ConvertExecResultToResponse returns the response decoded from the stdout if the exit code is zero, otherwise from
+// the stderr. The stderr of the succeeded command is returned as the warnings.
+func ConvertExecResultToResponse(result ExecResult, err error, defaultResponse *spec.Response) *spec.Response {
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "execContainer", err)
+	}
+	response := ConvertContainerOutputToResponse(result.Stdout, result.Err(), defaultResponse)
+	stderr := strings.TrimSpace(result.Stderr)
+	if response.Success && stderr != "" {
+		response.Result = execWarningResult{
+			Warnings: strings.Split(stderr, "\n"),
+			Result:   response.Result,
+		}
+	}
+	return response
+}
+
 func ConvertContainerOutputToResponse(output string, err error, defaultResponse *spec.Response) *spec.Response {
 	if err != nil {
 		response := spec.Decode(err.Error(), defaultResponse)
@@ -77,8 +134,14 @@ func (b *BaseDockerClientExecutor) SetClient(expModel *spec.ExpModel) error {
 
 // GetContainer return container by container flag, such as container id or container name.
 func GetContainer(client *Client, uid string, containerId, containerName string) (types.Container, *spec.Response) {
+	return getContainer(client, uid, containerId, containerName, false)
+}
+
+// getContainer return container by container id or container name, the name is a regular expression if nameRegex is true
+func getContainer(client *Client, uid string, containerId, containerName string, nameRegex bool) (types.Container, *spec.Response) {
 	if containerId == "" && containerName == "" {
-		tips := fmt.Sprintf("%s or %s", ContainerIdFlag.Name, ContainerNameFlag.Name)
+		tips := fmt.Sprintf("%s, %s, %s, %s or %s", ContainerIdFlag.Name, ContainerNameFlag.Name,
+			ContainerLabelFlag.Name, ComposeProjectFlag.Name, ComposeServiceFlag.Name)
 		util.Errorf(uid, util.GetRunFuncName(), spec.ParameterLess.Sprintf(tips))
 		return types.Container{}, spec.ResponseFailWithFlags(spec.ParameterLess, tips)
 	}
@@ -87,6 +150,8 @@ func GetContainer(client *Client, uid string, containerId, containerName string)
 	var err error
 	if containerId != "" {
 		container, err, code = client.getContainerById(containerId)
+	} else if nameRegex {
+		container, err, code = client.getContainerByNameRegex(containerName)
 	} else {
 		container, err, code = client.getContainerByName(containerName)
 	}
@@ -96,3 +161,274 @@ func GetContainer(client *Client, uid string, containerId, containerName string)
 	}
 	return container, spec.ReturnSuccess(container)
 }
+
+// ContainerResponse is the experiment result of one container selected by the container selector
+type ContainerResponse struct {
+	ContainerId   string      `json:"containerId"`
+	ContainerName string      `json:"containerName"`
+	Code          int32       `json:"code"`
+	Success       bool        `json:"success"`
+	Err           string      `json:"error,omitempty"`
+	Result        interface{} `json:"result,omitempty"`
+	// BladeVersion is the version of the blade tool which executes the experiment in the container
+	BladeVersion string `json:"bladeVersion,omitempty"`
+}
+
+const (
+	ComposeProjectLabel = "com.docker.compose.project"
+	ComposeServiceLabel = "com.docker.compose.service"
+)
+
+// isSelectedByLabels returns true if the containers are selected by the container-label or compose flags
+func isSelectedByLabels(flags map[string]string) bool {
+	return flags[ContainerIdFlag.Name] == "" && flags[ContainerNameFlag.Name] == "" &&
+		len(getSelectorLabels(flags)) > 0
+}
+
+// getSelectorLabels returns the labels from the container-label, compose-project and compose-service flags
+func getSelectorLabels(flags map[string]string) []string {
+	labels := parseContainerLabels(flags[ContainerLabelFlag.Name])
+	if project := strings.TrimSpace(flags[ComposeProjectFlag.Name]); project != "" {
+		labels = append(labels, fmt.Sprintf("%s=%s", ComposeProjectLabel, project))
+	}
+	if service := strings.TrimSpace(flags[ComposeServiceFlag.Name]); service != "" {
+		labels = append(labels, fmt.Sprintf("%s=%s", ComposeServiceLabel, service))
+	}
+	return labels
+}
+
+// parseContainerLabels parses the container-label flag value, the format is key=value[,key2=value2]
+func parseContainerLabels(value string) []string {
+	labels := make([]string, 0)
+	for _, label := range strings.Split(value, ",") {
+		label = strings.TrimSpace(label)
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// GetContainers returns the target containers by the container flags. The container-id and container-name flags
+// select one container, the container-label and compose flags select all running containers matching the labels. When
+// destroying, the containers recorded at creation are returned.
+func GetContainers(client *Client, uid string, ctx context.Context, flags map[string]string) ([]types.Container, *spec.Response) {
+	if !isSelectedByLabels(flags) {
+		nameRegex, _ := strconv.ParseBool(flags[ContainerNameRegexFlag.Name])
+		container, response := getContainer(client, uid, flags[ContainerIdFlag.Name], flags[ContainerNameFlag.Name], nameRegex)
+		if !response.Success {
+			return nil, response
+		}
+		return []types.Container{container}, response
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		// only the containers injected at creation are reverted, the containers matching the labels now may never
+		// be touched by the experiment
+		containerIds := make([]string, 0)
+		if _, err := loadRecord(suid, recordKeyContainers, &containerIds); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadContainers", err))
+			return nil, spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadContainers", err)
+		}
+		containers := make([]types.Container, 0, len(containerIds))
+		for _, containerId := range containerIds {
+			container, err, code := client.getContainerById(containerId)
+			if err != nil {
+				util.Errorf(uid, util.GetRunFuncName(), err.Error())
+				return nil, spec.ResponseFail(code, err.Error(), nil)
+			}
+			containers = append(containers, container)
+		}
+		return containers, spec.ReturnSuccess(containers)
+	}
+	containers, err, code := client.getContainersByLabels(getSelectorLabels(flags))
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), err.Error())
+		return nil, spec.ResponseFail(code, err.Error(), nil)
+	}
+	containers, response := sampleContainers(containers, flags)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return nil, response
+	}
+	return containers, spec.ReturnSuccess(containers)
+}
+
+// sampleContainers picks the containers by the container-count and container-percent flags. The candidates are
+// sorted by id and shuffled by the selection seed, so the same seed always picks the same containers.
+func sampleContainers(containers []types.Container, flags map[string]string) ([]types.Container, *spec.Response) {
+	countValue := flags[ContainerCountFlag.Name]
+	percentValue := flags[ContainerPercentFlag.Name]
+	if countValue == "" && percentValue == "" {
+		return containers, spec.ReturnSuccess(containers)
+	}
+	count := len(containers)
+	if countValue != "" {
+		value, err := strconv.Atoi(countValue)
+		if err != nil || value <= 0 {
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, ContainerCountFlag.Name, countValue,
+				"it must be a positive integer")
+		}
+		if value < count {
+			count = value
+		}
+	}
+	if percentValue != "" {
+		value, err := strconv.Atoi(percentValue)
+		if err != nil || value <= 0 || value > 100 {
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, ContainerPercentFlag.Name, percentValue,
+				"it must be a positive integer and not greater than 100")
+		}
+		if percentCount := int(math.Ceil(float64(len(containers)*value) / 100)); percentCount < count {
+			count = percentCount
+		}
+	}
+	seed := time.Now().UnixNano()
+	if seedValue := flags[SelectionSeedFlag.Name]; seedValue != "" {
+		value, err := strconv.ParseInt(seedValue, 10, 64)
+		if err != nil {
+			return nil, spec.ResponseFailWithFlags(spec.ParameterIllegal, SelectionSeedFlag.Name, seedValue,
+				"it must be an integer")
+		}
+		seed = value
+	}
+	candidates := make([]types.Container, len(containers))
+	copy(candidates, containers)
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ID < candidates[j].ID
+	})
+	random := rand.New(rand.NewSource(seed))
+	random.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return candidates[:count], spec.ReturnSuccess(candidates[:count])
+}
+
+// execInContainers resolves the target containers and invokes the execFunc for each of them. If the containers are
+// selected by labels, the injected containers are recorded against the uid and the per-container results are
+// aggregated into one response. All the records of the experiment are removed once it is destroyed.
+func execInContainers(uid string, ctx context.Context, client *Client, expModel *spec.ExpModel,
+	execFunc func(container types.Container) *spec.Response) *spec.Response {
+	return execInContainersWith(uid, ctx, client, expModel, false, execFunc)
+}
+
+// execInContainersConcurrently is the same as the execInContainers, but the execFunc is invoked for all the containers
+// at the same time, so the experiments waiting for the containers do not take longer with more containers
+func execInContainersConcurrently(uid string, ctx context.Context, client *Client, expModel *spec.ExpModel,
+	execFunc func(container types.Container) *spec.Response) *spec.Response {
+	return execInContainersWith(uid, ctx, client, expModel, true, execFunc)
+}
+
+func execInContainersWith(uid string, ctx context.Context, client *Client, expModel *spec.ExpModel,
+	concurrent bool, execFunc func(container types.Container) *spec.Response) *spec.Response {
+	suid, isDestroy := spec.IsDestroy(ctx)
+	containers, response := GetContainers(client, uid, ctx, expModel.ActionFlags)
+	if !response.Success {
+		return response
+	}
+	if !isSelectedByLabels(expModel.ActionFlags) {
+		response = execFunc(containers[0])
+	} else {
+		results := execForEachContainer(containers, concurrent, execFunc)
+		injectedIds := make([]string, 0, len(containers))
+		for _, result := range results {
+			if result.Success {
+				injectedIds = append(injectedIds, result.ContainerId)
+			}
+		}
+		if !isDestroy && len(injectedIds) > 0 {
+			if err := saveRecord(uid, recordKeyContainers, injectedIds); err != nil {
+				util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("save the containers record failed, %v", err))
+			}
+		}
+		response = aggregateContainerResponses(results, "execInContainers")
+	}
+	if isDestroy && response.Success {
+		removeExperimentRecords(uid, suid)
+	}
+	return response
+}
+
+// execForEachContainer invokes the execFunc for each container one by one or at the same time, the results are in
+// the order of the containers
+func execForEachContainer(containers []types.Container, concurrent bool,
+	execFunc func(container types.Container) *spec.Response) []ContainerResponse {
+	responses := make([]*spec.Response, len(containers))
+	if concurrent {
+		var wg sync.WaitGroup
+		for i := range containers {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i] = execFunc(containers[i])
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range containers {
+			responses[i] = execFunc(containers[i])
+		}
+	}
+	results := make([]ContainerResponse, 0, len(containers))
+	for i, container := range containers {
+		results = append(results, ContainerResponse{
+			ContainerId:   container.ID,
+			ContainerName: getContainerName(container),
+			Code:          responses[i].Code,
+			Success:       responses[i].Success,
+			Err:           responses[i].Err,
+			Result:        responses[i].Result,
+		})
+	}
+	return results
+}
+
+// aggregateContainerResponses returns the response of the containers, it fails if any of the containers fails
+func aggregateContainerResponses(results []ContainerResponse, action string) *spec.Response {
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return spec.ResponseFailWithResult(spec.DockerExecFailed, results, action,
+			fmt.Sprintf("%d of %d containers failed", failed, len(results)))
+	}
+	return spec.ReturnSuccess(results)
+}
+
+// removeExperimentRecords removes all the records of the experiment which is destroyed
+func removeExperimentRecords(uid, suid string) {
+	if err := removeRecords(suid); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the records of %s failed, %v", suid, err))
+	}
+}
+
+// getContainerName returns the container name without the leading slash
+func getContainerName(container types.Container) string {
+	if len(container.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(container.Names[0], "/")
+}
+
+// parseTimeout returns the seconds of the timeout flag, 0 means no timeout
+func parseTimeout(flags map[string]string) (int, *spec.Response) {
+	value := flags[TimeoutFlag]
+	if value == "" {
+		return 0, spec.Success()
+	}
+	timeout, err := strconv.Atoi(value)
+	if err != nil || timeout < 0 {
+		return 0, spec.ResponseFailWithFlags(spec.ParameterIllegal, TimeoutFlag, value, "it must be a non-negative integer")
+	}
+	return timeout, spec.Success()
+}
+
+// scheduleDestroy starts a background process which destroys the experiment after the timeout,
+// so that the experiment is recovered even if the destroy command is never executed
+func scheduleDestroy(ctx context.Context, uid string, timeout int) *spec.Response {
+	bladeBin := path.Join(util.GetProgramPath(), "blade")
+	return channel.NewLocalChannel().Run(ctx, "nohup",
+		fmt.Sprintf(`/bin/sh -c 'sleep %d; %s destroy %s' > /dev/null 2>&1 &`, timeout, bladeBin, uid))
+}
@@ -18,15 +18,20 @@ package exec
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/sirupsen/logrus"
 
-	"github.com/chaosblade-io/chaosblade-spec-go/channel"
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 
 	"github.com/chaosblade-io/chaosblade-exec-docker/version"
@@ -34,22 +39,39 @@ import (
 
 var defaultBladeTarFilePath = fmt.Sprintf("/opt/chaosblade-%s.tar.gz", version.BladeVersion)
 
+// deployedBladeDir is the directory of the chaosblade tool in the target container
+var deployedBladeDir = path.Join(DstChaosBladeDir, "chaosblade")
+
+// shellBin is required in the target container to create the experiments, because the blade tool executes the
+// experiments by the shell, but the deployment and the cleanup of the tool do not require it
+const shellBin = "/bin/sh"
+
+// bladeVersionRegex matches the version in the release file name and the output of the blade version command
+var bladeVersionRegex = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+// releasePlatformRegex matches the platform in the release name, such as chaosblade-1.5.0-linux-arm64.tar.gz
+var releasePlatformRegex = regexp.MustCompile(`-(linux|darwin)-(\w+)(\.tar|\.tar\.gz|\.tgz)?$`)
+
 // RunCmdInContainerExecutor is an executor interface which executes command in the target container directly
 type RunCmdInContainerExecutor interface {
 	spec.Executor
-	DeployChaosBlade(ctx context.Context, containerId string, srcFile, extractDirName string, override bool) error
+	DeployChaosBlade(ctx context.Context, containerId string, srcFile, extractDirName, bladeVersion string, override bool) error
 }
 
-// RunCmdInContainerExecutorByCP is an executor implementation which used copy chaosblade tool to the target container and executed
+// RunCmdInContainerExecutorByCP is an executor implementation which used copy chaosblade tool to the target container and executed.
+// The chaosblade tool is deployed and replaced by the archive api and the blade tool is executed with the argv, so the
+// deployment and the version, status and destroy commands do not require the shell. But the experiments cannot be
+// created in the container without the shell, such as the distroless and scratch containers, because the blade tool
+// executes the experiments by the shell, so the sidecar or nsenter exec mode must be used for them.
 type RunCmdInContainerExecutorByCP struct {
 	BaseDockerClientExecutor
+	// ArgsFunc returns the argv of the blade command executed in the container
+	ArgsFunc func(uid string, ctx context.Context, model *spec.ExpModel) []string
 }
 
 func NewRunCmdInContainerExecutorByCP() RunCmdInContainerExecutor {
 	return &RunCmdInContainerExecutorByCP{
-		BaseDockerClientExecutor{
-			CommandFunc: commonFunc,
-		},
+		ArgsFunc: commonArgsFunc,
 	}
 }
 
@@ -62,79 +84,309 @@ func (r *RunCmdInContainerExecutorByCP) Exec(uid string, ctx context.Context, ex
 		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
 		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
 	}
-	containerId := expModel.ActionFlags[ContainerIdFlag.Name]
-	containerName := expModel.ActionFlags[ContainerNameFlag.Name]
-	container, response := GetContainer(r.Client, uid, containerId, containerName)
-	if !response.Success {
-		return response
-	}
-	command := r.CommandFunc(uid, ctx, expModel)
-	if _, ok := spec.IsDestroy(ctx); !ok {
-		// Create
-		chaosbladeReleaseFile := expModel.ActionFlags[ChaosBladeReleaseFlag.Name]
-		if chaosbladeReleaseFile == "" {
-			chaosbladeReleaseFile = defaultBladeTarFilePath
+	// the blade version which executes the experiment is reported with the result of each container
+	bladeVersions := make(map[string]string)
+	response := execInContainers(uid, ctx, r.Client, expModel, func(container types.Container) *spec.Response {
+		response := r.execInContainer(uid, ctx, expModel, container)
+		if _, isDestroy := spec.IsDestroy(ctx); isDestroy || !response.Success {
+			return response
 		}
-		overrideValue := expModel.ActionFlags[ChaosBladeOverrideFlag.Name]
-		override, err := strconv.ParseBool(overrideValue)
-		if err != nil {
-			override = false
+		bladeVersion := r.getDeployedBladeVersion(ctx, container.ID)
+		if isSelectedByLabels(expModel.ActionFlags) {
+			bladeVersions[container.ID] = bladeVersion
+			return response
 		}
-		if resp, ok := channel.NewLocalChannel().IsAllCommandsAvailable(ctx, []string{"tar"}); !ok {
-			util.Errorf(uid, util.GetRunFuncName(), resp.Err)
-			return resp
+		response.Result = ContainerResponse{
+			ContainerId:   container.ID,
+			ContainerName: getContainerName(container),
+			Code:          response.Code,
+			Success:       response.Success,
+			Result:        response.Result,
+			BladeVersion:  bladeVersion,
 		}
+		return response
+	})
+	if results, ok := response.Result.([]ContainerResponse); ok {
+		setBladeVersions(results, bladeVersions)
+	}
+	return response
+}
 
-		response := channel.NewLocalChannel().Run(context.Background(), "tar",
-			fmt.Sprintf("tf %s| head -1 | cut -f1 -d/", chaosbladeReleaseFile))
-		if !response.Success {
-			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("`%s`: chaosblade-release parameter is invalid, err: %s", chaosbladeReleaseFile, response.Err))
-			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, response.Err)
-		}
-		if response.Result == nil {
-			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("`%s`: chaosblade-release parameter is invalid, extract directory failed", chaosbladeReleaseFile))
-			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, "the obtained directory name is nil")
-		}
-		extractedDirName := strings.TrimSpace(response.Result.(string))
-		if extractedDirName == "" {
-			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("`%s`: chaosblade-release parameter is invalid, extract empty directory failed", chaosbladeReleaseFile))
-			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, "the obtained directory name is empty")
+// setBladeVersions sets the blade version which executes the experiment in each container result
+func setBladeVersions(results []ContainerResponse, bladeVersions map[string]string) {
+	for i := range results {
+		results[i].BladeVersion = bladeVersions[results[i].ContainerId]
+	}
+}
 
+// execInContainer deploys the chaosblade tool to the container and executes the blade command in it
+func (r *RunCmdInContainerExecutorByCP) execInContainer(uid string, ctx context.Context, expModel *spec.ExpModel,
+	container types.Container) *spec.Response {
+	args := r.ArgsFunc(uid, ctx, expModel)
+	_, isDestroy := spec.IsDestroy(ctx)
+	if !isDestroy {
+		// Create
+		if !r.Client.existsInContainer(ctx, container.ID, shellBin) {
+			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("%s does not exist in %s", shellBin, container.ID))
+			msg := fmt.Sprintf("the blade tool executes the experiments by %s which does not exist in the container, "+
+				"use the %s or %s exec mode instead", shellBin, ExecModeSidecar, ExecModeNsenter)
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ExecModeFlag.Name, ExecModeCP, msg)
 		}
-		err = r.DeployChaosBlade(ctx, container.ID, chaosbladeReleaseFile, extractedDirName, override)
-		if err != nil {
-			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("DeployChaosBlade", err))
-			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "DeployChaosBlade", err)
+		if response := r.deploy(uid, ctx, expModel, container.ID); !response.Success {
+			return response
 		}
 	}
-	output, err := r.Client.execContainer(container.ID, command)
+	result, err := r.execBlade(container.ID, args)
 	var defaultResponse *spec.Response
 	if err != nil {
 		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("execContainer", err))
 		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "execContainer", err)
 	}
-	return ConvertContainerOutputToResponse(output, err, defaultResponse)
+	response := ConvertExecResultToResponse(result, err, defaultResponse)
+	if isDestroy && response.Success && expModel.ActionFlags[ChaosBladeCleanupFlag.Name] == "true" {
+		r.cleanup(uid, ctx, container.ID)
+	}
+	return response
+}
+
+// deploy deploys the chaosblade tool from the chaosblade-tool image if the chaosblade-from-image flag is specified,
+// otherwise from the chaosblade release file
+func (r *RunCmdInContainerExecutorByCP) deploy(uid string, ctx context.Context, expModel *spec.ExpModel,
+	containerId string) *spec.Response {
+	platform := r.getImagePlatform(containerId)
+	chaosbladeReleaseFile := expModel.ActionFlags[ChaosBladeReleaseFlag.Name]
+	fromImage := expModel.ActionFlags[ChaosBladeFromImageFlag.Name] == "true"
+	if chaosbladeReleaseFile == "" && !fromImage {
+		chaosbladeReleaseFile = getDefaultReleaseFile(platform)
+		if chaosbladeReleaseFile == "" {
+			util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the release file of %s is not found", platform))
+			return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, path.Dir(defaultBladeTarFilePath),
+				fmt.Sprintf("the release file of %s is not found, specify the %s or %s flag", platform,
+					ChaosBladeReleaseFlag.Name, ChaosBladeFromImageFlag.Name))
+		}
+	}
+	releasePlatform := getReleasePlatform(chaosbladeReleaseFile)
+	if !fromImage && platform != "" && releasePlatform != "" && releasePlatform != platform {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("`%s`: the release is built for %s, but the container is %s",
+			chaosbladeReleaseFile, releasePlatform, platform))
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile,
+			fmt.Sprintf("the release is built for %s, but the container is %s", releasePlatform, platform))
+	}
+	overrideValue := expModel.ActionFlags[ChaosBladeOverrideFlag.Name]
+	override, err := strconv.ParseBool(overrideValue)
+	if err != nil {
+		override = false
+	}
+	if fromImage {
+		imageVersion := expModel.ActionFlags[ImageVersionFlag.Name]
+		image := getChaosBladeImageRef(expModel.ActionFlags[ImageRepoFlag.Name], imageVersion)
+		// the version of the latest image is unknown, so the deployed blade tool is not checked by the version
+		bladeVersion := bladeVersionRegex.FindString(imageVersion)
+		if err := r.DeployChaosBladeFromImage(ctx, containerId, image, platform, bladeVersion, override); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("DeployChaosBladeFromImage", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "DeployChaosBladeFromImage", err)
+		}
+		return spec.Success()
+	}
+	extractedDirName, err := inspectRelease(chaosbladeReleaseFile)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("`%s`: chaosblade-release parameter is invalid, err: %v", chaosbladeReleaseFile, err))
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ChaosBladeReleaseFlag.Name, chaosbladeReleaseFile, err)
+	}
+	bladeVersion := bladeVersionRegex.FindString(extractedDirName)
+	if bladeVersion == "" {
+		bladeVersion = bladeVersionRegex.FindString(path.Base(chaosbladeReleaseFile))
+	}
+	if bladeVersion == "" {
+		bladeVersion = version.BladeVersion
+	}
+	err = r.DeployChaosBlade(ctx, containerId, chaosbladeReleaseFile, extractedDirName, bladeVersion, override)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("DeployChaosBlade", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "DeployChaosBlade", err)
+	}
+	return spec.Success()
 }
 
 func (r *RunCmdInContainerExecutorByCP) SetChannel(channel spec.Channel) {
 }
 
+// DeployChaosBlade copies the release file to the container, the deployed blade tool is overridden if the override
+// is true or its version is not the bladeVersion. The files of the deployed tool are replaced by the copied files, the
+// chaosblade.dat data file and the logs are kept, because the data file records the experiments not destroyed yet.
 func (r *RunCmdInContainerExecutorByCP) DeployChaosBlade(ctx context.Context, containerId string,
-	srcFile, extractDirName string, override bool) error {
-	// check if the blade tool exists
-	output, err := r.Client.execContainerPrivileged(containerId, fmt.Sprintf("[ -e %s ] && echo True || echo False", BladeBin))
-	logrus.Debugf("output: %s, %v", output, err)
-	if err == nil && strings.Contains(output, "True") && !override {
+	srcFile, extractDirName, bladeVersion string, override bool) error {
+	// check if the blade tool with the same version exists
+	if !override && r.isChaosBladeDeployed(ctx, containerId, bladeVersion) {
 		return nil
 	}
-	err = r.Client.CopyToContainer(context.TODO(), containerId, srcFile, DstChaosBladeDir, override)
+	// the extracted directory is renamed in the copied stream
+	return r.Client.copyReleaseToContainer(ctx, containerId, srcFile, extractDirName, DstChaosBladeDir,
+		path.Base(deployedBladeDir), override)
+}
+
+// DeployChaosBladeFromImage copies the chaosblade tool from a stopped container of the chaosblade-tool image to the
+// target container, so neither the release file nor the tar command is required on the host. The image variant of
+// the platform is used if the platform is not empty.
+func (r *RunCmdInContainerExecutorByCP) DeployChaosBladeFromImage(ctx context.Context, containerId, image, platform,
+	bladeVersion string, override bool) error {
+	if !override && r.isChaosBladeDeployed(ctx, containerId, bladeVersion) {
+		return nil
+	}
+	// the image is pulled before the deployed tool is removed, so the tool is kept if the image is not available
+	image, err := r.Client.ensureImagePlatform(image, platform)
 	if err != nil {
 		return err
 	}
-	dstBladeDir := path.Join(DstChaosBladeDir, extractDirName)
-	expectBladeDir := path.Join(DstChaosBladeDir, "chaosblade")
-	renameCmd := fmt.Sprintf("rm -rf %s && mv %s %s", expectBladeDir, dstBladeDir, expectBladeDir)
-	logrus.Debugf("renameCmd: %s", renameCmd)
-	_, err = r.Client.execContainerPrivileged(containerId, renameCmd)
-	return err
+	toolContainerId, err := r.Client.createContainer(&container.Config{
+		Image: image,
+		Cmd:   []string{"true"},
+		Labels: map[string]string{
+			SidecarLabel: "chaosblade-tool",
+		},
+	}, &container.HostConfig{}, &network.NetworkingConfig{}, "")
+	if err != nil {
+		return err
+	}
+	defer r.Client.forceRemoveContainer(toolContainerId)
+	return r.Client.copyBetweenContainers(ctx, toolContainerId, deployedBladeDir, containerId, DstChaosBladeDir, override)
+}
+
+// isChaosBladeDeployed returns true if the blade tool exists in the container and its version is the bladeVersion,
+// any version is accepted if the bladeVersion is empty
+func (r *RunCmdInContainerExecutorByCP) isChaosBladeDeployed(ctx context.Context, containerId, bladeVersion string) bool {
+	if !r.Client.existsInContainer(ctx, containerId, BladeBin) {
+		return false
+	}
+	if bladeVersion == "" {
+		return true
+	}
+	deployedVersion := r.getDeployedBladeVersion(ctx, containerId)
+	if deployedVersion != bladeVersion {
+		logrus.Infof("the deployed blade version %s in %s is not %s, redeploy it", deployedVersion, containerId, bladeVersion)
+		return false
+	}
+	return true
+}
+
+// getDeployedBladeVersion returns the version of the blade tool in the container, or empty if it is unknown
+func (r *RunCmdInContainerExecutorByCP) getDeployedBladeVersion(ctx context.Context, containerId string) string {
+	result, err := r.execBlade(containerId, []string{BladeBin, "version"})
+	if err == nil {
+		err = result.Err()
+	}
+	if err != nil {
+		logrus.Warningf("get the blade version in %s failed, %v", containerId, err)
+		return ""
+	}
+	// the output format is `version: 1.5.0`
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "version:") {
+			return bladeVersionRegex.FindString(line)
+		}
+	}
+	return ""
+}
+
+// cleanup removes the chaosblade tool with its logs and data file from the container if no experiment is running in
+// it, the failure of the cleanup does not fail the destroy
+func (r *RunCmdInContainerExecutorByCP) cleanup(uid string, ctx context.Context, containerId string) {
+	result, err := r.execBlade(containerId, []string{BladeBin, "status", "--type", "create", "--status", "Success"})
+	if err == nil {
+		err = result.Err()
+	}
+	if err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("get the running experiments in %s failed, %v", containerId, err))
+		return
+	}
+	running, err := countRunningExperiments(result.Stdout)
+	if err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("get the running experiments in %s failed, %v", containerId, err))
+		return
+	}
+	if running > 0 {
+		util.Infof(uid, util.GetRunFuncName(), fmt.Sprintf("%d experiments are running in %s, skip the cleanup", running, containerId))
+		return
+	}
+	// the logs and the chaosblade.dat data file are in the chaosblade directory
+	if err := r.removeChaosBlade(ctx, containerId); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove %s in %s failed, %v", deployedBladeDir, containerId, err))
+	}
+}
+
+// countRunningExperiments returns the count of the experiments in the output of the blade status command
+func countRunningExperiments(output string) (int, error) {
+	response := spec.Decode(output, nil)
+	if !response.Success {
+		return 0, errors.New(response.Err)
+	}
+	if experiments, ok := response.Result.([]interface{}); ok {
+		return len(experiments), nil
+	}
+	return 0, nil
+}
+
+// execBlade executes the blade tool in the container with the argv directly, so the arguments are not parsed by the shell
+func (r *RunCmdInContainerExecutorByCP) execBlade(containerId string, args []string) (ExecResult, error) {
+	return r.Client.execContainerForResult(containerId, args)
+}
+
+// removeChaosBlade removes the deployed chaosblade directory with the logs and the data file, the rm command is
+// executed with the argv, so the shell is not required
+func (r *RunCmdInContainerExecutorByCP) removeChaosBlade(ctx context.Context, containerId string) error {
+	result, err := r.Client.execContainerForResult(containerId, []string{"rm", "-rf", deployedBladeDir})
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// getImagePlatform returns the os/arch platform of the container image, or empty if it is unknown
+func (r *RunCmdInContainerExecutorByCP) getImagePlatform(containerId string) string {
+	containerJSON, err := r.Client.inspectContainer(containerId)
+	if err != nil {
+		logrus.Warningf("get the image of %s failed, %v", containerId, err)
+		return ""
+	}
+	imageInspect, err := r.Client.getImageInspectById(containerJSON.Image)
+	if err != nil {
+		logrus.Warningf("get the platform of the image %s failed, %v", containerJSON.Image, err)
+		return ""
+	}
+	return getPlatform(imageInspect.Os, imageInspect.Architecture)
+}
+
+// getDefaultReleaseFile returns the release file of the platform in the release directory, the release file without
+// the platform in the name is built for the host, it is returned even if it does not exist, so the missing release
+// file is reported by the chaosblade-release flag. It returns empty if the platform is not the host platform and
+// its release file is not found.
+func getDefaultReleaseFile(platform string) string {
+	if platform != "" {
+		platformReleaseFile := path.Join(path.Dir(defaultBladeTarFilePath),
+			fmt.Sprintf("chaosblade-%s-%s.tar.gz", version.BladeVersion, strings.Replace(platform, "/", "-", 1)))
+		if util.IsExist(platformReleaseFile) {
+			return platformReleaseFile
+		}
+	}
+	if platform != "" && platform != getPlatform(runtime.GOOS, runtime.GOARCH) {
+		logrus.Warningf("the release file of %s is not found in %s", platform, path.Dir(defaultBladeTarFilePath))
+		return ""
+	}
+	return defaultBladeTarFilePath
+}
+
+// getReleasePlatform returns the platform in the release name, or empty if the name does not contain it
+func getReleasePlatform(releaseFile string) string {
+	matches := releasePlatformRegex.FindStringSubmatch(path.Base(releaseFile))
+	if len(matches) < 3 {
+		return ""
+	}
+	return getPlatform(matches[1], matches[2])
+}
+
+func getPlatform(os, arch string) string {
+	if os == "" || arch == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", os, arch)
 }
@@ -0,0 +1,90 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetReleasePlatform(t *testing.T) {
+	tests := []struct {
+		name        string
+		releaseFile string
+		want        string
+	}{
+		{"gzip compressed tar", "/opt/chaosblade-1.7.3-linux-arm64.tar.gz", "linux/arm64"},
+		{"tar", "chaosblade-1.7.3-linux-amd64.tar", "linux/amd64"},
+		{"tgz", "chaosblade-1.7.3-darwin-amd64.tgz", "darwin/amd64"},
+		{"unpacked directory", "/opt/chaosblade-1.7.3-linux-arm64", "linux/arm64"},
+		{"without the platform", "/opt/chaosblade-1.7.3.tar.gz", ""},
+		{"unsupported os", "chaosblade-1.7.3-windows-amd64.tar.gz", ""},
+		{"platform in the directory only", "/opt/linux-arm64/chaosblade.tar.gz", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getReleasePlatform(tt.releaseFile); got != tt.want {
+				t.Errorf("getReleasePlatform(%q) = %q, want %q", tt.releaseFile, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBladeVersions(t *testing.T) {
+	results := []ContainerResponse{
+		{ContainerId: "c1", Success: true, Result: "uid1"},
+		{ContainerId: "c2", Success: false, Err: "exec failed"},
+	}
+	setBladeVersions(results, map[string]string{"c1": "1.7.3"})
+	want := []ContainerResponse{
+		{ContainerId: "c1", Success: true, Result: "uid1", BladeVersion: "1.7.3"},
+		{ContainerId: "c2", Success: false, Err: "exec failed"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("setBladeVersions() = %+v, want %+v", results, want)
+	}
+}
+
+func TestCountRunningExperiments(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:   "running experiments",
+			output: `{"code":200,"success":true,"result":[{"Uid":"a1","Status":"Success"},{"Uid":"b2","Status":"Success"}]}`,
+			want:   2,
+		},
+		{name: "no experiment", output: `{"code":200,"success":true,"result":[]}`, want: 0},
+		{name: "no result", output: `{"code":200,"success":true}`, want: 0},
+		{name: "status failed", output: `{"code":63010,"success":false,"error":"query failed"}`, wantErr: true},
+		{name: "not a response", output: "blade: command not found", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countRunningExperiments(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("countRunningExperiments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("countRunningExperiments() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	// ExecModeCP copies the chaosblade tool to the target container and executes the experiment in it
+	ExecModeCP = "cp"
+	// ExecModeSidecar executes the experiment in the chaosblade-tool sidecar of the target container
+	ExecModeSidecar = "sidecar"
+	// ExecModeNsenter executes the experiment by the chaos_os tool of the host in the namespaces of the target container
+	ExecModeNsenter = "nsenter"
+	// ExecModeAuto probes the target containers and uses the first mode which can work
+	ExecModeAuto = "auto"
+)
+
+// recordKeyExecMode is the record key of the mode used to create the experiment
+const recordKeyExecMode = "exec-mode"
+
+// ExecModeExecutor dispatches the experiment to the executor of the exec-mode flag
+type ExecModeExecutor struct {
+	// modes are the supported modes in the order probed by the auto mode, the first one is the default mode
+	modes     []string
+	executors map[string]spec.Executor
+}
+
+// modeExecutor is the executor of the mode
+type modeExecutor struct {
+	mode     string
+	executor spec.Executor
+}
+
+// execModeResult is the response result of the auto mode
+type execModeResult struct {
+	ExecMode string            `json:"execMode"`
+	Skipped  map[string]string `json:"skipped,omitempty"`
+	Result   interface{}       `json:"result"`
+}
+
+// NewNetworkExecModeExecutor returns the executor of the network experiments, the sidecar mode is the default mode
+func NewNetworkExecModeExecutor() *ExecModeExecutor {
+	return newExecModeExecutor(
+		modeExecutor{ExecModeSidecar, NewNetWorkSidecarExecutor()},
+		modeExecutor{ExecModeNsenter, NewRunCmdWithNsenterExecutor()},
+	)
+}
+
+// NewResourceExecModeExecutor returns the executor of the cpu, mem and process experiments, the cp mode is the
+// default mode
+func NewResourceExecModeExecutor() *ExecModeExecutor {
+	return newExecModeExecutor(
+		modeExecutor{ExecModeCP, NewRunCmdInContainerExecutorByCP()},
+		modeExecutor{ExecModeSidecar, NewResourceSidecarExecutor()},
+		modeExecutor{ExecModeNsenter, NewRunCmdWithNsenterExecutor()},
+	)
+}
+
+// NewFileExecModeExecutor returns the executor of the disk and file experiments which only support the cp mode,
+// because the sidecar and the host chaos_os tool do not share the filesystem of the target container
+func NewFileExecModeExecutor() *ExecModeExecutor {
+	return newExecModeExecutor(
+		modeExecutor{ExecModeCP, NewRunCmdInContainerExecutorByCP()},
+	)
+}
+
+func newExecModeExecutor(modeExecutors ...modeExecutor) *ExecModeExecutor {
+	e := &ExecModeExecutor{
+		modes:     make([]string, 0, len(modeExecutors)),
+		executors: make(map[string]spec.Executor, len(modeExecutors)),
+	}
+	for _, m := range modeExecutors {
+		e.modes = append(e.modes, m.mode)
+		e.executors[m.mode] = m.executor
+	}
+	return e
+}
+
+func (e *ExecModeExecutor) Name() string {
+	return "execMode"
+}
+
+func (e *ExecModeExecutor) SetChannel(channel spec.Channel) {
+	for _, executor := range e.executors {
+		executor.SetChannel(channel)
+	}
+}
+
+func (e *ExecModeExecutor) Exec(uid string, ctx context.Context, expModel *spec.ExpModel) *spec.Response {
+	mode := e.getExecMode(expModel.ActionFlags)
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		// destroy the experiment by the mode used to create it
+		var createdMode string
+		exists, err := loadRecord(suid, recordKeyExecMode, &createdMode)
+		if err != nil {
+			util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("load the exec mode record of %s failed, %v", suid, err))
+		}
+		var response *spec.Response
+		if exists {
+			response = e.execByMode(uid, ctx, expModel, createdMode)
+		} else if mode == ExecModeAuto {
+			response = e.destroyAuto(uid, ctx, expModel)
+		} else {
+			response = e.execByMode(uid, ctx, expModel, mode)
+		}
+		if response.Success {
+			if err := removeRecord(suid, recordKeyExecMode); err != nil {
+				util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the exec mode record of %s failed, %v", suid, err))
+			}
+		}
+		return response
+	}
+	if mode == ExecModeAuto {
+		return e.execAuto(uid, ctx, expModel)
+	}
+	response := e.execByMode(uid, ctx, expModel, mode)
+	if response.Success {
+		e.saveExecMode(uid, mode)
+	}
+	return response
+}
+
+// saveExecMode records the mode used to create the experiment, so the experiment is destroyed by the same mode
+func (e *ExecModeExecutor) saveExecMode(uid, mode string) {
+	if err := saveRecord(uid, recordKeyExecMode, mode); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("save the exec mode record of %s failed, %v", uid, err))
+	}
+}
+
+// getExecMode returns the mode of the exec-mode flag, or the default mode if it is not specified
+func (e *ExecModeExecutor) getExecMode(flags map[string]string) string {
+	if mode := flags[ExecModeFlag.Name]; mode != "" {
+		return mode
+	}
+	return e.modes[0]
+}
+
+// destroyAuto destroys the experiment created by the auto mode whose mode is not recorded, the modes are tried in the
+// order of the probe until one of them destroys the experiment, because the mode used to create it is unknown
+func (e *ExecModeExecutor) destroyAuto(uid string, ctx context.Context, expModel *spec.ExpModel) *spec.Response {
+	reasons := make([]string, 0, len(e.modes))
+	for _, mode := range e.modes {
+		response := e.execByMode(uid, ctx, expModel, mode)
+		if response.Success {
+			return response
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", mode, response.Err))
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("destroy the experiment by the %s mode failed, try the next mode, %s",
+			mode, response.Err))
+	}
+	util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("no exec mode can destroy the experiment, %s",
+		strings.Join(reasons, "; ")))
+	return spec.ResponseFailWithFlags(spec.ParameterIllegal, ExecModeFlag.Name, ExecModeAuto,
+		fmt.Sprintf("no mode can destroy the experiment, %s", strings.Join(reasons, "; ")))
+}
+
+func (e *ExecModeExecutor) execByMode(uid string, ctx context.Context, expModel *spec.ExpModel, mode string) *spec.Response {
+	executor, ok := e.executors[mode]
+	if !ok {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the %s mode is not supported by %s", mode, expModel.Target))
+		return spec.ResponseFailWithFlags(spec.ParameterIllegal, ExecModeFlag.Name, mode,
+			fmt.Sprintf("the supported modes are %s and %s", strings.Join(e.modes, ", "), ExecModeAuto))
+	}
+	return executor.Exec(uid, ctx, expModel)
+}
+
+// execAuto executes the experiment by the first mode which can work for all target containers. The mode is skipped if
+// the probe or the execution fails, and the experiment created by the failed execution is destroyed.
+func (e *ExecModeExecutor) execAuto(uid string, ctx context.Context, expModel *spec.ExpModel) *spec.Response {
+	client, err := GetClient(expModel.ActionFlags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	// the seed is fixed so that the executor picks the same containers as the probe, it is set in the copy of the
+	// model, so the model of the experiment is not changed
+	execModel := *expModel
+	execModel.ActionFlags = make(map[string]string, len(expModel.ActionFlags)+1)
+	for name, value := range expModel.ActionFlags {
+		execModel.ActionFlags[name] = value
+	}
+	flags := execModel.ActionFlags
+	if flags[SelectionSeedFlag.Name] == "" && (flags[ContainerCountFlag.Name] != "" || flags[ContainerPercentFlag.Name] != "") {
+		flags[SelectionSeedFlag.Name] = strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	containers, response := GetContainers(client, uid, ctx, flags)
+	if !response.Success {
+		return response
+	}
+	skipped := make(map[string]string)
+	for _, mode := range e.modes {
+		if reason := e.probeMode(ctx, client, &execModel, mode, containers); reason != "" {
+			skipped[mode] = reason
+			continue
+		}
+		response := e.execByMode(uid, ctx, &execModel, mode)
+		if response.Success {
+			e.saveExecMode(uid, mode)
+			response.Result = execModeResult{ExecMode: mode, Skipped: skipped, Result: response.Result}
+			return response
+		}
+		skipped[mode] = response.Err
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("the %s mode failed, try the next mode, %s", mode, response.Err))
+		if destroyResponse := e.execByMode(uid, spec.SetDestroyFlag(ctx, uid), &execModel, mode); !destroyResponse.Success {
+			util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("destroy the experiment of the %s mode failed, %s",
+				mode, destroyResponse.Err))
+		}
+	}
+	reasons := make([]string, 0, len(skipped))
+	for _, mode := range e.modes {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", mode, skipped[mode]))
+	}
+	util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("no exec mode can work, %s", strings.Join(reasons, "; ")))
+	return spec.ResponseFailWithFlags(spec.ParameterIllegal, ExecModeFlag.Name, ExecModeAuto,
+		fmt.Sprintf("no mode can work, %s", strings.Join(reasons, "; ")))
+}
+
+// probeMode returns the reason why the mode cannot work, or empty if the mode can work
+func (e *ExecModeExecutor) probeMode(ctx context.Context, client *Client, expModel *spec.ExpModel, mode string,
+	containers []types.Container) string {
+	switch mode {
+	case ExecModeCP:
+		for _, container := range containers {
+			containerJSON, err := client.inspectContainer(container.ID)
+			if err != nil {
+				return err.Error()
+			}
+			if containerJSON.HostConfig != nil && containerJSON.HostConfig.ReadonlyRootfs {
+				return fmt.Sprintf("the root filesystem of %s is read-only", getContainerName(container))
+			}
+			if containerJSON.State == nil || !containerJSON.State.Running {
+				return fmt.Sprintf("%s is not running", getContainerName(container))
+			}
+			if !client.existsInContainer(ctx, container.ID, shellBin) {
+				return fmt.Sprintf("%s does not exist in %s", shellBin, getContainerName(container))
+			}
+		}
+	case ExecModeSidecar:
+		image := getChaosBladeImageRef(expModel.ActionFlags[ImageRepoFlag.Name], expModel.ActionFlags[ImageVersionFlag.Name])
+		if _, err := client.getImageByRef(image); err != nil {
+			if _, err := client.pullImage(image); err != nil {
+				return fmt.Sprintf("the image %s cannot be pulled, %v", image, err)
+			}
+			if _, err := client.getImageByRef(image); err != nil {
+				return fmt.Sprintf("the image %s is not found after it is pulled, %v", image, err)
+			}
+		}
+	case ExecModeNsenter:
+		if _, ok := nsenterTargets[expModel.Target]; !ok {
+			return fmt.Sprintf("the %s target is not supported", expModel.Target)
+		}
+		for _, bin := range []string{getHostChaosOsBin(), getHostNsexecBin()} {
+			if !util.IsExist(bin) {
+				return fmt.Sprintf("the %s tool is not found on the host", bin)
+			}
+		}
+	}
+	return ""
+}
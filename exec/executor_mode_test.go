@@ -0,0 +1,128 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+)
+
+// fakeModeExecutor records the modes executed and returns the response of the mode
+type fakeModeExecutor struct {
+	mode     string
+	success  bool
+	executed *[]string
+}
+
+func (f *fakeModeExecutor) Name() string {
+	return f.mode
+}
+
+func (f *fakeModeExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (f *fakeModeExecutor) Exec(uid string, ctx context.Context, expModel *spec.ExpModel) *spec.Response {
+	*f.executed = append(*f.executed, f.mode)
+	if f.success {
+		return spec.ReturnSuccess(f.mode)
+	}
+	return spec.ResponseFailWithFlags(spec.DockerExecFailed, f.mode, "failed")
+}
+
+func newFakeExecModeExecutor(executed *[]string, succeeded ...string) *ExecModeExecutor {
+	modeExecutors := make([]modeExecutor, 0)
+	for _, mode := range []string{ExecModeCP, ExecModeSidecar, ExecModeNsenter} {
+		success := false
+		for _, s := range succeeded {
+			success = success || s == mode
+		}
+		modeExecutors = append(modeExecutors, modeExecutor{mode, &fakeModeExecutor{mode, success, executed}})
+	}
+	return newExecModeExecutor(modeExecutors...)
+}
+
+func TestGetExecMode(t *testing.T) {
+	e := NewResourceExecModeExecutor()
+	tests := []struct {
+		name  string
+		flags map[string]string
+		want  string
+	}{
+		{name: "default mode", flags: map[string]string{}, want: ExecModeCP},
+		{name: "exec mode flag", flags: map[string]string{ExecModeFlag.Name: ExecModeNsenter}, want: ExecModeNsenter},
+		{name: "auto mode", flags: map[string]string{ExecModeFlag.Name: ExecModeAuto}, want: ExecModeAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.getExecMode(tt.flags); got != tt.want {
+				t.Errorf("getExecMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := NewNetworkExecModeExecutor().getExecMode(map[string]string{}); got != ExecModeSidecar {
+		t.Errorf("getExecMode() of the network = %q, want %q", got, ExecModeSidecar)
+	}
+}
+
+func TestExecModeExecutorDestroyWithoutRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		succeeded   []string
+		wantSuccess bool
+		wantModes   []string
+	}{
+		{
+			name:        "auto mode tries the modes until one destroys it",
+			mode:        ExecModeAuto,
+			succeeded:   []string{ExecModeSidecar, ExecModeNsenter},
+			wantSuccess: true,
+			wantModes:   []string{ExecModeCP, ExecModeSidecar},
+		},
+		{
+			name:      "auto mode fails if no mode destroys it",
+			mode:      ExecModeAuto,
+			wantModes: []string{ExecModeCP, ExecModeSidecar, ExecModeNsenter},
+		},
+		{
+			name:        "the specified mode is used",
+			mode:        ExecModeNsenter,
+			succeeded:   []string{ExecModeNsenter},
+			wantSuccess: true,
+			wantModes:   []string{ExecModeNsenter},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executed := make([]string, 0)
+			e := newFakeExecModeExecutor(&executed, tt.succeeded...)
+			// the experiment uid has no exec mode record
+			ctx := spec.SetDestroyFlag(context.Background(), "exec-mode-test-not-recorded")
+			expModel := &spec.ExpModel{ActionFlags: map[string]string{ExecModeFlag.Name: tt.mode}}
+			response := e.Exec("exec-mode-test-not-recorded", ctx, expModel)
+			if response.Success != tt.wantSuccess {
+				t.Errorf("Exec() success = %v, want %v, err: %s", response.Success, tt.wantSuccess, response.Err)
+			}
+			if !reflect.DeepEqual(executed, tt.wantModes) {
+				t.Errorf("Exec() executed modes = %v, want %v", executed, tt.wantModes)
+			}
+		})
+	}
+}
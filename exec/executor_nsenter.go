@@ -0,0 +1,390 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/channel"
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+// recordKeyNsenterUid is the record key prefix of the uid of the experiment created by the chaos_os tool
+const recordKeyNsenterUid = "nsenter-uid"
+
+const (
+	// nsenterCommandTimeout is the timeout of the chaos_os tool which does not hang
+	nsenterCommandTimeout = 60 * time.Second
+	// nsenterStartTime is the time to wait for the process hang experiment to fail before it is regarded as started
+	nsenterStartTime = time.Second
+)
+
+// nsenterTargets are the experiment targets supported by the nsenter executor and the namespaces joined by the
+// nsexec channel of the chaos_os tool. The cpu and the memory experiments run on the host in the cgroup of the
+// container, and the chaos_os tool computes their percentages by the cgroup of the container, the experiments on
+// the files and the processes are not supported because the tool does not run in the mount namespace.
+var nsenterTargets = map[string][]string{
+	"cpu":     {},
+	"mem":     {},
+	"network": {channel.NSNetFlagName},
+}
+
+// nsenterCgroupControllers are the cgroup v1 controllers which the chaos_os tool is moved to, so the cpu, the memory
+// and the processes created by the tool are limited and accounted by the container
+var nsenterCgroupControllers = []string{"cpu", "cpuacct", "cpuset", "memory", "pids"}
+
+var (
+	// nsenterModelSpec is the model spec which the actions and the flags of the chaos_os tool are looked up in
+	nsenterModelSpec     *dockerExpModelSpec
+	nsenterModelSpecOnce sync.Once
+)
+
+// getNsenterModelSpec returns the model spec created once, the executors are not created again for each experiment
+func getNsenterModelSpec() *dockerExpModelSpec {
+	nsenterModelSpecOnce.Do(func() {
+		nsenterModelSpec = NewDockerExpModelSpec()
+	})
+	return nsenterModelSpec
+}
+
+// RunCmdWithNsenterExecutor is an executor implementation which executes the chaos_os tool of the host by the nsexec
+// channel in the namespaces and the cgroup of the target container, so neither the chaosblade tool nor the
+// chaosblade-tool image is required
+type RunCmdWithNsenterExecutor struct {
+	BaseDockerClientExecutor
+}
+
+func NewRunCmdWithNsenterExecutor() *RunCmdWithNsenterExecutor {
+	return &RunCmdWithNsenterExecutor{}
+}
+
+func (r *RunCmdWithNsenterExecutor) Name() string {
+	return "runCmdWithNsenter"
+}
+
+func (r *RunCmdWithNsenterExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (r *RunCmdWithNsenterExecutor) Exec(uid string, ctx context.Context, expModel *spec.ExpModel) *spec.Response {
+	if _, ok := nsenterTargets[expModel.Target]; !ok {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the %s target is not supported by nsenter", expModel.Target))
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, ExecModeFlag.Name, ExecModeNsenter,
+			fmt.Sprintf("the %s target is not supported", expModel.Target))
+	}
+	modelSpec := getNsenterModelSpec()
+	actionSpec := modelSpec.GetExpActionModelSpec(expModel.Target, expModel.ActionName)
+	if actionSpec == nil {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the %s action is not found", expModel.ActionName))
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, "action", expModel.ActionName, "the action is not found")
+	}
+	if err := r.SetClient(expModel); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	for _, bin := range []string{getHostChaosOsBin(), getHostNsexecBin()} {
+		if !util.IsExist(bin) {
+			util.Errorf(uid, util.GetRunFuncName(), spec.ChaosbladeFileNotFound.Sprintf(bin))
+			return spec.ResponseFailWithFlags(spec.ChaosbladeFileNotFound, bin)
+		}
+	}
+	flagNames := getChaosOsFlagNames(modelSpec.ExpModels()[expModel.Target], actionSpec)
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, r.Client, expModel, func(container types.Container) *spec.Response {
+			return r.destroy(uid, suid, ctx, expModel, actionSpec, flagNames, container.ID)
+		})
+	}
+	return execInContainers(uid, ctx, r.Client, expModel, func(container types.Container) *spec.Response {
+		return r.create(uid, ctx, expModel, actionSpec, flagNames, container.ID)
+	})
+}
+
+// create executes the chaos_os tool in the namespaces and the cgroup of the container and records the uid of it
+func (r *RunCmdWithNsenterExecutor) create(uid string, ctx context.Context, expModel *spec.ExpModel,
+	actionSpec spec.ExpActionCommandSpec, flagNames map[string]spec.Empty, containerId string) *spec.Response {
+	containerJSON, err := r.Client.inspectContainer(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	if containerJSON.State == nil || !containerJSON.State.Running || containerJSON.State.Pid == 0 {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is not running", containerId))
+		return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", "not running")
+	}
+	cgroupProcsFiles, err := getChaosOsCgroupProcsFiles(containerJSON.State.Pid)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetContainerCgroup", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetContainerCgroup", err)
+	}
+	flags := make(map[string]string, len(expModel.ActionFlags)+1)
+	for name, value := range expModel.ActionFlags {
+		flags[name] = value
+	}
+	// the cpu percentage is computed by the cpu count, which is all the cpus of the host if it is not specified
+	if expModel.Target == "cpu" && flags["cpu-count"] == "" && flags["cpu-list"] == "" {
+		if count := getContainerCpuCount(containerJSON.HostConfig); count > 0 {
+			flags["cpu-count"] = strconv.Itoa(count)
+		}
+	}
+	// the uid is different for each container, so the experiment of one container is destroyed by its own uid
+	osUid := fmt.Sprintf("%s-%s", uid, containerJSON.ID)
+	args := getChaosOsArgs(spec.Create, expModel.Target, actionSpec.Name(), flags, flagNames, osUid,
+		containerJSON.State.Pid)
+	var response *spec.Response
+	if actionSpec.ProcessHang() {
+		response = startChaosOs(uid, containerJSON.ID, cgroupProcsFiles, args)
+	} else {
+		response = runChaosOs(ctx, cgroupProcsFiles, args)
+	}
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	recordKey := fmt.Sprintf("%s-%s", recordKeyNsenterUid, containerJSON.ID)
+	if err := saveRecord(uid, recordKey, osUid); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SaveNsenterUid", err))
+		runChaosOs(ctx, nil, getChaosOsArgs(spec.Destroy, expModel.Target, actionSpec.Name(), flags, flagNames, osUid,
+			containerJSON.State.Pid))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveNsenterUid", err)
+	}
+	return response
+}
+
+// destroy destroys the experiment of the chaos_os tool in the namespaces of the container, nothing is destroyed if
+// the container is not running, because the namespaces, the cgroup and the processes in it are removed with it
+func (r *RunCmdWithNsenterExecutor) destroy(uid, suid string, ctx context.Context, expModel *spec.ExpModel,
+	actionSpec spec.ExpActionCommandSpec, flagNames map[string]spec.Empty, containerId string) *spec.Response {
+	recordKey := fmt.Sprintf("%s-%s", recordKeyNsenterUid, containerId)
+	var osUid string
+	exists, err := loadRecord(suid, recordKey, &osUid)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadNsenterUid", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadNsenterUid", err)
+	}
+	if !exists {
+		return spec.ReturnSuccess(uid)
+	}
+	response := spec.ReturnSuccess(uid)
+	containerJSON, err := r.Client.inspectContainer(containerId)
+	if err == nil && containerJSON.State != nil && containerJSON.State.Running && containerJSON.State.Pid > 0 {
+		response = runChaosOs(ctx, nil, getChaosOsArgs(spec.Destroy, expModel.Target, actionSpec.Name(),
+			expModel.ActionFlags, flagNames, osUid, containerJSON.State.Pid))
+	}
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	if err := removeRecord(suid, recordKey); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the nsenter record of %s failed, %v", suid, err))
+	}
+	if err := os.Remove(getNsenterLogFile(suid, containerId)); err != nil && !os.IsNotExist(err) {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the nsenter log of %s failed, %v", suid, err))
+	}
+	return response
+}
+
+// getNsenterLogFile returns the file of the output of the chaos_os tool which is started in the background
+func getNsenterLogFile(uid, containerId string) string {
+	return path.Join(getRecordDir(uid), fmt.Sprintf("%s-%s.log", recordKeyNsenterUid, containerId))
+}
+
+// getHostBinPath returns the bin directory of the chaosblade tool of the host
+func getHostBinPath() string {
+	programPath := util.GetProgramPath()
+	if path.Base(programPath) == spec.BinPath {
+		return programPath
+	}
+	return path.Join(programPath, spec.BinPath)
+}
+
+// getHostChaosOsBin returns the chaos_os tool of the host
+func getHostChaosOsBin() string {
+	return path.Join(getHostBinPath(), spec.ChaosOsBin)
+}
+
+// getHostNsexecBin returns the nsexec tool of the host which is used by the nsexec channel of the chaos_os tool
+func getHostNsexecBin() string {
+	return path.Join(getHostBinPath(), spec.NSExecBin)
+}
+
+// getChaosOsFlagNames returns the flags accepted by the chaos_os tool for the action, the other flags, such as the
+// timeout flag, make the tool fail
+func getChaosOsFlagNames(commandSpec spec.ExpModelCommandSpec, actionSpec spec.ExpActionCommandSpec) map[string]spec.Empty {
+	names := make(map[string]spec.Empty)
+	flagSpecs := append(actionSpec.Flags(), actionSpec.Matchers()...)
+	if commandSpec != nil {
+		flagSpecs = append(flagSpecs, commandSpec.Flags()...)
+	}
+	dockerFlagNames := GetAllDockerFlagNames()
+	for _, flagSpec := range flagSpecs {
+		if _, ok := dockerFlagNames[flagSpec.FlagName()]; ok {
+			continue
+		}
+		names[flagSpec.FlagName()] = spec.Empty{}
+	}
+	return names
+}
+
+// getChaosOsArgs returns the argv of the chaos_os tool which uses the nsexec channel to execute the commands in the
+// namespaces of the process
+func getChaosOsArgs(mode, target, action string, flags map[string]string, flagNames map[string]spec.Empty,
+	osUid string, pid int) []string {
+	args := []string{getHostChaosOsBin(), mode, target, action}
+	names := make([]string, 0, len(flags))
+	for name, value := range flags {
+		if _, ok := flagNames[name]; !ok || value == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("--%s=%s", name, flags[name]))
+	}
+	args = append(args,
+		fmt.Sprintf("--uid=%s", osUid),
+		fmt.Sprintf("--channel=%s", spec.NSExecBin),
+		fmt.Sprintf("--%s=%d", channel.NSTargetFlagName, pid))
+	for _, name := range nsenterTargets[target] {
+		args = append(args, fmt.Sprintf("--%s=%s", name, spec.True))
+	}
+	return args
+}
+
+// newChaosOsCmd returns the command which moves the shell to the cgroup of the container, so the processes created
+// by the chaos_os tool are limited by the container, and executes the tool. The tool is not executed if the shell is
+// not moved to any of the cgroups.
+func newChaosOsCmd(ctx context.Context, cgroupProcsFiles []string, args []string) *exec.Cmd {
+	script := `exec "$@"`
+	if len(cgroupProcsFiles) > 0 {
+		script = fmt.Sprintf(`for f in %s; do echo $$ > "$f" || { echo "move to the cgroup $f failed" >&2; exit 1; }; done; %s`,
+			strings.Join(cgroupProcsFiles, " "), script)
+	}
+	logrus.Debugf("chaos_os command: %s %v", script, args)
+	return exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script, "sh"}, args...)...)
+}
+
+// runChaosOs executes the chaos_os tool and decodes the output of it to the response
+func runChaosOs(ctx context.Context, cgroupProcsFiles []string, args []string) *spec.Response {
+	timeoutCtx, cancel := context.WithTimeout(ctx, nsenterCommandTimeout)
+	defer cancel()
+	output, err := newChaosOsCmd(timeoutCtx, cgroupProcsFiles, args).CombinedOutput()
+	return decodeChaosOsOutput(string(output), err)
+}
+
+// startChaosOs starts the chaos_os tool of the process hang experiment in the background, the experiment is regarded
+// as started if the tool does not exit in the start time
+func startChaosOs(uid, containerId string, cgroupProcsFiles []string, args []string) *spec.Response {
+	if err := os.MkdirAll(getRecordDir(uid), 0755); err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "CreateRecordDir", err)
+	}
+	logFile := getNsenterLogFile(uid, containerId)
+	output, err := os.Create(logFile)
+	if err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "CreateLogFile", err)
+	}
+	defer output.Close()
+	cmd := newChaosOsCmd(context.Background(), cgroupProcsFiles, append([]string{"nohup"}, args...))
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "StartChaosOs", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		bytes, _ := ioutil.ReadFile(logFile)
+		response := decodeChaosOsOutput(string(bytes), err)
+		if response.Success {
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "StartChaosOs",
+				fmt.Sprintf("the chaos_os tool exited, %s", strings.TrimSpace(string(bytes))))
+		}
+		return response
+	case <-time.After(nsenterStartTime):
+		return spec.ReturnSuccess(uid)
+	}
+}
+
+// decodeChaosOsOutput returns the response printed by the chaos_os tool, the same as the channels of the tool
+func decodeChaosOsOutput(output string, err error) *spec.Response {
+	if strings.TrimSpace(output) != "" {
+		response := spec.Decode(output, nil)
+		if response.Code != spec.ResultUnmarshalFailed.Code {
+			return response
+		}
+	}
+	if err == nil {
+		return spec.ReturnSuccess(output)
+	}
+	return spec.ResponseFailWithFlags(spec.OsCmdExecFailed, spec.ChaosOsBin, fmt.Sprintf("%s %v", output, err))
+}
+
+// getContainerCpuCount returns the cpus limited by the cpu quota of the container, 0 means no limit
+func getContainerCpuCount(hostConfig *container.HostConfig) int {
+	if hostConfig == nil {
+		return 0
+	}
+	if hostConfig.NanoCPUs > 0 {
+		return int(math.Ceil(float64(hostConfig.NanoCPUs) / 1e9))
+	}
+	if hostConfig.CPUQuota > 0 {
+		period := hostConfig.CPUPeriod
+		if period <= 0 {
+			// the default cfs period of the kernel is 100ms
+			period = 100000
+		}
+		return int(math.Ceil(float64(hostConfig.CPUQuota) / float64(period)))
+	}
+	return 0
+}
+
+// getChaosOsCgroupProcsFiles returns the cgroup.procs files of the cgroups of the container process which the chaos_os
+// tool is moved to, there is only one cgroup for the cgroup v2
+func getChaosOsCgroupProcsFiles(pid int) ([]string, error) {
+	files := make([]string, 0, len(nsenterCgroupControllers))
+	exists := make(map[string]bool, len(nsenterCgroupControllers))
+	for _, controller := range nsenterCgroupControllers {
+		cgroupPath, err := getCgroupPath(pid, controller)
+		if err != nil {
+			return nil, err
+		}
+		// the controllers may be mounted together, such as cpu,cpuacct
+		if exists[cgroupPath] {
+			continue
+		}
+		exists[cgroupPath] = true
+		files = append(files, path.Join(cgroupPath, "cgroup.procs"))
+	}
+	return files, nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestGetChaosOsArgs(t *testing.T) {
+	flagNames := map[string]spec.Empty{"cpu-percent": {}, "timeout": {}, "interface": {}, "time": {}}
+	tests := []struct {
+		name   string
+		mode   string
+		target string
+		action string
+		flags  map[string]string
+		want   []string
+	}{
+		{
+			name:   "the flags not accepted by the tool are dropped",
+			mode:   spec.Create,
+			target: "cpu",
+			action: "fullload",
+			flags:  map[string]string{"cpu-percent": "60", "container-id": "ee54f1e61c08", "exec-mode": "nsenter"},
+			want: []string{getHostChaosOsBin(), "create", "cpu", "fullload", "--cpu-percent=60",
+				"--uid=uid-ee54f1e61c08", "--channel=nsexec", "--ns_target=1234"},
+		},
+		{
+			name:   "the flags are sorted and the empty flags are dropped",
+			mode:   spec.Create,
+			target: "network",
+			action: "delay",
+			flags:  map[string]string{"time": "3000", "interface": "eth0", "timeout": ""},
+			want: []string{getHostChaosOsBin(), "create", "network", "delay", "--interface=eth0", "--time=3000",
+				"--uid=uid-ee54f1e61c08", "--channel=nsexec", "--ns_target=1234", "--ns_net=true"},
+		},
+		{
+			name:   "destroy",
+			mode:   spec.Destroy,
+			target: "mem",
+			action: "load",
+			flags:  map[string]string{},
+			want: []string{getHostChaosOsBin(), "destroy", "mem", "load", "--uid=uid-ee54f1e61c08",
+				"--channel=nsexec", "--ns_target=1234"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getChaosOsArgs(tt.mode, tt.target, tt.action, tt.flags, flagNames, "uid-ee54f1e61c08", 1234)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getChaosOsArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetContainerCpuCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		hostConfig *container.HostConfig
+		want       int
+	}{
+		{name: "no host config", want: 0},
+		{name: "no limit", hostConfig: &container.HostConfig{}, want: 0},
+		{name: "cpus", hostConfig: &container.HostConfig{Resources: container.Resources{NanoCPUs: 1500000000}}, want: 2},
+		{name: "cpu quota of the default period", hostConfig: &container.HostConfig{Resources: container.Resources{CPUQuota: 200000}}, want: 2},
+		{
+			name:       "cpu quota of the period",
+			hostConfig: &container.HostConfig{Resources: container.Resources{CPUQuota: 150000, CPUPeriod: 50000}},
+			want:       3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getContainerCpuCount(tt.hostConfig); got != tt.want {
+				t.Errorf("getContainerCpuCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,9 +19,11 @@ package exec
 import (
 	"context"
 	"fmt"
+	"path"
 	"time"
 
 	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/sirupsen/logrus"
@@ -29,10 +31,21 @@ import (
 	"github.com/chaosblade-io/chaosblade-spec-go/spec"
 )
 
+const (
+	// SidecarLabel is the label of all sidecar containers created by chaosblade
+	SidecarLabel = "chaosblade"
+	// SidecarUidLabel is the label of the experiment uid which creates the sidecar
+	SidecarUidLabel = "chaosblade-uid"
+	// SidecarTargetLabel is the label of the target container id of the sidecar
+	SidecarTargetLabel = "chaosblade-target"
+)
+
 type RunInSidecarContainerExecutor struct {
 	BaseDockerClientExecutor
-	runConfigFunc func(container string) (container.HostConfig, network.NetworkingConfig)
-	isResident    bool
+	runConfigFunc func(client *Client, container types.Container) (container.HostConfig, network.NetworkingConfig, error)
+	// isResident is true if the sidecar is always kept alive until the experiment is destroyed, otherwise the sidecar
+	// is only kept alive if the resident-sidecar flag is specified
+	isResident bool
 }
 
 func (*RunInSidecarContainerExecutor) Name() string {
@@ -44,36 +57,132 @@ func (r *RunInSidecarContainerExecutor) Exec(uid string, ctx context.Context, ex
 		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
 		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
 	}
-	containerId := expModel.ActionFlags[ContainerIdFlag.Name]
-	containerName := expModel.ActionFlags[ContainerNameFlag.Name]
-	container, response := GetContainer(r.Client, uid, containerId, containerName)
-	if !response.Success {
-		return response
-	}
-	hostConfig, networkingConfig := r.runConfigFunc(container.ID)
-	sidecarName := createSidecarContainerName(container.Names[0], expModel.Target, expModel.ActionName)
-	return r.startAndExecInContainer(uid, ctx, expModel, &hostConfig, &networkingConfig, sidecarName)
+	resident := r.isResidentSidecar(expModel)
+	return execInContainers(uid, ctx, r.Client, expModel, func(container types.Container) *spec.Response {
+		sidecarName := createSidecarContainerName(container.Names[0], expModel.Target, expModel.ActionName)
+		if suid, ok := spec.IsDestroy(ctx); ok && resident {
+			if response, ok := r.execInResidentSidecar(uid, suid, ctx, expModel, container.ID); ok {
+				return response
+			}
+		} else if resident {
+			// the resident sidecars of the experiments on the same container are distinguished by the uid
+			sidecarName = fmt.Sprintf("%s-%s", sidecarName, uid)
+		}
+		hostConfig, networkingConfig, err := r.runConfigFunc(r.Client, container)
+		if err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetSidecarConfig", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetSidecarConfig", err)
+		}
+		return r.startAndExecInContainer(uid, ctx, expModel, &hostConfig, &networkingConfig, sidecarName, container.ID, resident)
+	})
 }
 
+// isResidentSidecar returns true if the sidecar is kept alive until the experiment is destroyed
+func (r *RunInSidecarContainerExecutor) isResidentSidecar(expModel *spec.ExpModel) bool {
+	return r.isResident || expModel.ActionFlags[ResidentSidecarFlag.Name] == spec.True
+}
+
+// NewNetWorkSidecarExecutor returns the sidecar executor for the network experiments. The sidecar is removed after
+// the command is executed by default, it is resident if the resident-sidecar flag is specified, so the experiment can
+// be destroyed in the same sidecar even if the chaosblade-tool image is not available.
 func NewNetWorkSidecarExecutor() *RunInSidecarContainerExecutor {
-	runConfigFunc := func(containerId string) (container.HostConfig, network.NetworkingConfig) {
+	runConfigFunc := func(client *Client, ctn types.Container) (container.HostConfig, network.NetworkingConfig, error) {
 		hostConfig := container.HostConfig{
-			NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", containerId)),
+			NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", ctn.ID)),
 			CapAdd:      []string{"NET_ADMIN"},
 		}
 		networkConfig := network.NetworkingConfig{}
-		return hostConfig, networkConfig
+		return hostConfig, networkConfig, nil
 	}
 	return &RunInSidecarContainerExecutor{
 		// set the client when invoking
 		runConfigFunc: runConfigFunc,
-		isResident:    false,
 		BaseDockerClientExecutor: BaseDockerClientExecutor{
 			CommandFunc: commonFunc,
 		},
 	}
 }
 
+// NewResourceSidecarExecutor returns the sidecar executor for the cpu, mem and process experiments. The sidecar joins
+// the pid and ipc namespaces of the target container, and is placed in the cgroup of the target container, so the
+// load created in the sidecar is accounted to the target container. The sidecar is resident because the load
+// processes are stopped when the sidecar is removed.
+func NewResourceSidecarExecutor() *RunInSidecarContainerExecutor {
+	runConfigFunc := func(client *Client, ctn types.Container) (container.HostConfig, network.NetworkingConfig, error) {
+		containerJSON, err := client.inspectContainer(ctn.ID)
+		if err != nil {
+			return container.HostConfig{}, network.NetworkingConfig{}, err
+		}
+		cgroupParent, err := getSidecarCgroupParent(client, containerJSON)
+		if err != nil {
+			return container.HostConfig{}, network.NetworkingConfig{}, err
+		}
+		hostConfig := container.HostConfig{
+			PidMode: container.PidMode(fmt.Sprintf("container:%s", containerJSON.ID)),
+			IpcMode: getSidecarIpcMode(containerJSON),
+			Resources: container.Resources{
+				CgroupParent: cgroupParent,
+			},
+		}
+		return hostConfig, network.NetworkingConfig{}, nil
+	}
+	return &RunInSidecarContainerExecutor{
+		runConfigFunc: runConfigFunc,
+		isResident:    true,
+		BaseDockerClientExecutor: BaseDockerClientExecutor{
+			CommandFunc: commonFunc,
+		},
+	}
+}
+
+// getSidecarCgroupParent returns the cgroup of the target container as the cgroup parent of the sidecar, so the load
+// is accounted to the target container. Only the cgroupfs driver with cgroup v1 allows it: the systemd driver does
+// not support the nested cgroup in the container scope, and cgroup v2 does not allow the child cgroup of the cgroup
+// which has processes. The cgroup version is detected on the host, so the remote docker daemon is not supported.
+func getSidecarCgroupParent(client *Client, containerJSON types.ContainerJSON) (string, error) {
+	driver, err := client.getCgroupDriver()
+	if err != nil {
+		return "", err
+	}
+	if driver == "systemd" {
+		return "", fmt.Errorf("the sidecar cannot be placed in the cgroup of the container by the systemd cgroup "+
+			"driver, use the %s or %s exec mode instead", ExecModeCP, ExecModeNsenter)
+	}
+	if !client.isLocalDaemon() {
+		return "", fmt.Errorf("the cgroup version of the remote docker daemon is unknown, the sidecar cannot be "+
+			"placed in the cgroup of the container, use the %s exec mode instead", ExecModeCP)
+	}
+	if isCgroupV2() {
+		return "", fmt.Errorf("the sidecar cannot be placed in the cgroup of the container by cgroup v2, "+
+			"use the %s or %s exec mode instead", ExecModeCP, ExecModeNsenter)
+	}
+	cgroupParent := ""
+	if containerJSON.HostConfig != nil {
+		cgroupParent = containerJSON.HostConfig.CgroupParent
+	}
+	if cgroupParent == "" {
+		cgroupParent = "/docker"
+	}
+	return path.Join(cgroupParent, containerJSON.ID), nil
+}
+
+// getSidecarIpcMode returns the ipc mode joining the target container, the private ipc namespace cannot be joined
+func getSidecarIpcMode(containerJSON types.ContainerJSON) container.IpcMode {
+	if containerJSON.HostConfig == nil {
+		return ""
+	}
+	ipcMode := containerJSON.HostConfig.IpcMode
+	switch {
+	case ipcMode.IsHost(), ipcMode.IsContainer():
+		// join the same ipc namespace as the target
+		return ipcMode
+	case ipcMode.IsPrivate(), ipcMode.IsNone():
+		return ""
+	default:
+		return container.IpcMode(fmt.Sprintf("container:%s", containerJSON.ID))
+	}
+}
+
 func createSidecarContainerName(containerName, target, injectType string) string {
 	return fmt.Sprintf("%s-%s-%s", containerName, target, injectType)
 }
@@ -81,7 +190,7 @@ func createSidecarContainerName(containerName, target, injectType string) string
 func (*RunInSidecarContainerExecutor) SetChannel(channel spec.Channel) {
 }
 
-func (r *RunInSidecarContainerExecutor) getContainerConfig(expModel *spec.ExpModel) *container.Config {
+func (r *RunInSidecarContainerExecutor) getContainerConfig(uid string, expModel *spec.ExpModel, targetContainerId string) *container.Config {
 	return &container.Config{
 		// detach
 		AttachStdout: false,
@@ -91,23 +200,67 @@ func (r *RunInSidecarContainerExecutor) getContainerConfig(expModel *spec.ExpMod
 		Image: getChaosBladeImageRef(expModel.ActionFlags[ImageRepoFlag.Name],
 			expModel.ActionFlags[ImageVersionFlag.Name]),
 		Labels: map[string]string{
-			"chaosblade": "chaosblade-sidecar",
+			SidecarLabel:       "chaosblade-sidecar",
+			SidecarUidLabel:    uid,
+			SidecarTargetLabel: targetContainerId,
 		},
 	}
 }
 
 func (r *RunInSidecarContainerExecutor) startAndExecInContainer(uid string, ctx context.Context, expModel *spec.ExpModel,
-	hostConfig *container.HostConfig, networkConfig *network.NetworkingConfig, containerName string) *spec.Response {
-	config := r.getContainerConfig(expModel)
+	hostConfig *container.HostConfig, networkConfig *network.NetworkingConfig, containerName, targetContainerId string,
+	resident bool) *spec.Response {
+	config := r.getContainerConfig(uid, expModel, targetContainerId)
 	var defaultResponse *spec.Response
 	command := r.CommandFunc(uid, ctx, expModel)
-	sidecarContainerId, output, err, code := r.Client.executeAndRemove(
-		config, hostConfig, networkConfig, containerName, true, time.Second, command)
+	_, isDestroy := spec.IsDestroy(ctx)
+	removed := !resident || isDestroy
+	sidecarContainerId, result, err, code := r.Client.executeAndRemove(
+		config, hostConfig, networkConfig, containerName, removed, time.Second, command)
 	if err != nil {
+		if !removed && sidecarContainerId != "" {
+			r.removeSidecar(sidecarContainerId)
+		}
 		util.Errorf(uid, util.GetRunFuncName(), err.Error())
 		return spec.ResponseFail(code, err.Error(), nil)
 	}
-	returnedResponse := ConvertContainerOutputToResponse(output, err, defaultResponse)
-	logrus.Infof("sidecarContainerId for experiment %s is %s, output is %s, err is %v", uid, sidecarContainerId, output, err)
+	returnedResponse := ConvertExecResultToResponse(result, err, defaultResponse)
+	if !removed && !returnedResponse.Success {
+		r.removeSidecar(sidecarContainerId)
+	}
+	logrus.Infof("sidecarContainerId for experiment %s is %s, result is %+v", uid, sidecarContainerId, result)
 	return returnedResponse
 }
+
+// execInResidentSidecar executes the command in the resident sidecar created by the experiment and removes it,
+// returns false if the sidecar does not exist
+func (r *RunInSidecarContainerExecutor) execInResidentSidecar(uid, suid string, ctx context.Context,
+	expModel *spec.ExpModel, targetContainerId string) (*spec.Response, bool) {
+	sidecars, err := r.Client.listContainersByLabels([]string{
+		fmt.Sprintf("%s=%s", SidecarUidLabel, suid),
+		fmt.Sprintf("%s=%s", SidecarTargetLabel, targetContainerId),
+	})
+	if err != nil || len(sidecars) == 0 {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("the resident sidecar of %s is not found, %v", suid, err))
+		return nil, false
+	}
+	var response *spec.Response
+	for _, sidecar := range sidecars {
+		if sidecar.State == "running" && response == nil {
+			var defaultResponse *spec.Response
+			result, err := r.Client.execContainerForResult(sidecar.ID, []string{"sh", "-c", r.CommandFunc(uid, ctx, expModel)})
+			logrus.Infof("sidecarContainerId for experiment %s is %s, result is %+v, err is %v", uid, sidecar.ID, result, err)
+			response = ConvertExecResultToResponse(result, err, defaultResponse)
+		}
+		r.removeSidecar(sidecar.ID)
+	}
+	return response, response != nil
+}
+
+// removeSidecar stops and removes the sidecar container
+func (r *RunInSidecarContainerExecutor) removeSidecar(sidecarContainerId string) {
+	timeout := time.Second
+	if err := r.Client.stopAndRemoveContainer(sidecarContainerId, &timeout); err != nil {
+		logrus.Warningf("remove the sidecar container %s failed, %v", sidecarContainerId, err)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"testing"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestGetSidecarIpcMode(t *testing.T) {
+	const containerId = "0123456789abcdef"
+	tests := []struct {
+		name       string
+		hostConfig *container.HostConfig
+		want       container.IpcMode
+	}{
+		{"no host config", nil, ""},
+		{"host", &container.HostConfig{IpcMode: "host"}, "host"},
+		{"container", &container.HostConfig{IpcMode: "container:fedcba9876543210"}, "container:fedcba9876543210"},
+		{"private", &container.HostConfig{IpcMode: "private"}, ""},
+		{"none", &container.HostConfig{IpcMode: "none"}, ""},
+		{"shareable", &container.HostConfig{IpcMode: "shareable"}, "container:" + containerId},
+		{"default", &container.HostConfig{}, "container:" + containerId},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerJSON := types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: containerId, HostConfig: tt.hostConfig},
+			}
+			if got := getSidecarIpcMode(containerJSON); got != tt.want {
+				t.Errorf("getSidecarIpcMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsResidentSidecar(t *testing.T) {
+	tests := []struct {
+		name     string
+		executor *RunInSidecarContainerExecutor
+		flags    map[string]string
+		want     bool
+	}{
+		{name: "network sidecar is one-shot by default", executor: NewNetWorkSidecarExecutor(), flags: map[string]string{}},
+		{
+			name:     "network sidecar is resident by the flag",
+			executor: NewNetWorkSidecarExecutor(),
+			flags:    map[string]string{ResidentSidecarFlag.Name: spec.True},
+			want:     true,
+		},
+		{name: "resource sidecar is always resident", executor: NewResourceSidecarExecutor(), flags: map[string]string{}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expModel := &spec.ExpModel{ActionFlags: tt.flags}
+			if got := tt.executor.isResidentSidecar(expModel); got != tt.want {
+				t.Errorf("isResidentSidecar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
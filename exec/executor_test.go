@@ -0,0 +1,268 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/docker/docker/api/types"
+)
+
+func TestParseContainerLabels(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"empty", "", []string{}},
+		{"one label", "app=web", []string{"app=web"}},
+		{"key only", "app", []string{"app"}},
+		{"multiple labels with spaces", " app=web , tier=frontend ,", []string{"app=web", "tier=frontend"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseContainerLabels(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseContainerLabels(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSelectorLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    map[string]string
+		want     []string
+		selected bool
+	}{
+		{"no selector", map[string]string{}, []string{}, false},
+		{"container label", map[string]string{"container-label": "app=web"}, []string{"app=web"}, true},
+		{
+			name:     "compose project and service",
+			flags:    map[string]string{"compose-project": " shop ", "compose-service": "web"},
+			want:     []string{ComposeProjectLabel + "=shop", ComposeServiceLabel + "=web"},
+			selected: true,
+		},
+		{
+			name:     "container label and compose service",
+			flags:    map[string]string{"container-label": "tier=frontend", "compose-service": "web"},
+			want:     []string{"tier=frontend", ComposeServiceLabel + "=web"},
+			selected: true,
+		},
+		{
+			name:     "container id is preferred",
+			flags:    map[string]string{"container-id": "ee54f1e61c08", "container-label": "app=web"},
+			want:     []string{"app=web"},
+			selected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getSelectorLabels(tt.flags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getSelectorLabels() = %v, want %v", got, tt.want)
+			}
+			if got := isSelectedByLabels(tt.flags); got != tt.selected {
+				t.Errorf("isSelectedByLabels() = %v, want %v", got, tt.selected)
+			}
+		})
+	}
+}
+
+func TestAggregateContainerResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []ContainerResponse
+		success bool
+	}{
+		{"no containers", []ContainerResponse{}, true},
+		{"all succeeded", []ContainerResponse{{ContainerId: "a", Success: true}, {ContainerId: "b", Success: true}}, true},
+		{"one failed", []ContainerResponse{{ContainerId: "a", Success: true}, {ContainerId: "b", Err: "failed"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := aggregateContainerResponses(tt.results, "execInContainers")
+			if response.Success != tt.success {
+				t.Fatalf("aggregateContainerResponses() success = %v, want %v", response.Success, tt.success)
+			}
+			if tt.success && response.Code != spec.OK.Code {
+				t.Errorf("aggregateContainerResponses() code = %d, want %d", response.Code, spec.OK.Code)
+			}
+			if !tt.success && response.Code != spec.DockerExecFailed.Code {
+				t.Errorf("aggregateContainerResponses() code = %d, want %d", response.Code, spec.DockerExecFailed.Code)
+			}
+			// the results of all the containers are returned even if some of them failed
+			if !reflect.DeepEqual(response.Result, tt.results) {
+				t.Errorf("aggregateContainerResponses() result = %v, want %v", response.Result, tt.results)
+			}
+		})
+	}
+}
+
+func containerIds(containers []types.Container) []string {
+	ids := make([]string, 0, len(containers))
+	for _, container := range containers {
+		ids = append(ids, container.ID)
+	}
+	return ids
+}
+
+func TestSampleContainers(t *testing.T) {
+	containers := []types.Container{{ID: "e"}, {ID: "a"}, {ID: "d"}, {ID: "b"}, {ID: "c"}}
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		count   int
+		success bool
+	}{
+		{"no sampling", map[string]string{}, 5, true},
+		{"count", map[string]string{"container-count": "2", "selection-seed": "1"}, 2, true},
+		{"count greater than the containers", map[string]string{"container-count": "10", "selection-seed": "1"}, 5, true},
+		{"percent rounded up", map[string]string{"container-percent": "50", "selection-seed": "1"}, 3, true},
+		{"the smaller of count and percent", map[string]string{"container-count": "1", "container-percent": "100", "selection-seed": "1"}, 1, true},
+		{"zero count", map[string]string{"container-count": "0"}, 0, false},
+		{"illegal count", map[string]string{"container-count": "two"}, 0, false},
+		{"percent greater than 100", map[string]string{"container-percent": "101"}, 0, false},
+		{"illegal seed", map[string]string{"container-count": "1", "selection-seed": "seed"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampled, response := sampleContainers(containers, tt.flags)
+			if response.Success != tt.success {
+				t.Fatalf("sampleContainers() success = %v, want %v, err: %s", response.Success, tt.success, response.Err)
+			}
+			if len(sampled) != tt.count {
+				t.Errorf("sampleContainers() returns %d containers, want %d", len(sampled), tt.count)
+			}
+		})
+	}
+}
+
+func TestSampleContainersBySeed(t *testing.T) {
+	flags := map[string]string{"container-count": "3", "selection-seed": "42"}
+	first, _ := sampleContainers([]types.Container{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}, {ID: "e"}}, flags)
+	// the same containers in another order are sampled the same by the same seed
+	second, _ := sampleContainers([]types.Container{{ID: "e"}, {ID: "d"}, {ID: "c"}, {ID: "b"}, {ID: "a"}}, flags)
+	if !reflect.DeepEqual(containerIds(first), containerIds(second)) {
+		t.Errorf("sampleContainers() = %v and %v, want the same containers by the same seed",
+			containerIds(first), containerIds(second))
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		success bool
+	}{
+		{"no timeout", "", 0, true},
+		{"zero", "0", 0, true},
+		{"seconds", "60", 60, true},
+		{"negative", "-1", 0, false},
+		{"not an integer", "1m", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, response := parseTimeout(map[string]string{TimeoutFlag: tt.value})
+			if response.Success != tt.success {
+				t.Fatalf("parseTimeout() success = %v, want %v", response.Success, tt.success)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeout() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecForEachContainer(t *testing.T) {
+	containers := []types.Container{{ID: "a", Names: []string{"/web-1"}}, {ID: "b", Names: []string{"/web-2"}}, {ID: "c"}}
+	tests := []struct {
+		name       string
+		concurrent bool
+		maxElapsed time.Duration
+	}{
+		{"one by one", false, time.Minute},
+		// the containers are waited at the same time, so it takes about one wait instead of three
+		{"concurrent", true, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			results := execForEachContainer(containers, tt.concurrent, func(container types.Container) *spec.Response {
+				time.Sleep(100 * time.Millisecond)
+				if container.ID == "b" {
+					return spec.ResponseFailWithFlags(spec.UnexpectedStatus, "running", "exited")
+				}
+				return spec.ReturnSuccess(container.ID)
+			})
+			if elapsed := time.Since(start); elapsed > tt.maxElapsed {
+				t.Errorf("execForEachContainer() takes %v, want less than %v", elapsed, tt.maxElapsed)
+			}
+			want := []ContainerResponse{
+				{ContainerId: "a", ContainerName: "web-1", Code: spec.OK.Code, Success: true, Result: "a"},
+				{ContainerId: "b", ContainerName: "web-2", Code: spec.UnexpectedStatus.Code,
+					Err: spec.UnexpectedStatus.Sprintf("running", "exited")},
+				{ContainerId: "c", Code: spec.OK.Code, Success: true, Result: "c"},
+			}
+			if !reflect.DeepEqual(results, want) {
+				t.Errorf("execForEachContainer() = %+v, want %+v", results, want)
+			}
+		})
+	}
+}
+
+func TestCommonArgsFunc(t *testing.T) {
+	flags := map[string]string{
+		"time":                     "3000",
+		"interface":                "eth0",
+		"offset":                   "",
+		ContainerIdFlag.Name:       "ee54f1e61c08",
+		ExecModeFlag.Name:          ExecModeCP,
+		ChaosBladeReleaseFlag.Name: "/opt/chaosblade-1.7.3.tar.gz",
+		"remote-port":              "80,8080",
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{
+			name: "create",
+			ctx:  context.Background(),
+			want: []string{BladeBin, "create", "network", "delay", "--interface=eth0", "--remote-port=80,8080",
+				"--time=3000", "--uid", "uid"},
+		},
+		{
+			name: "destroy",
+			ctx:  spec.SetDestroyFlag(context.Background(), "uid"),
+			want: []string{BladeBin, "destroy", "network", "delay", "--interface=eth0", "--remote-port=80,8080",
+				"--time=3000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := &spec.ExpModel{Target: "network", ActionName: "delay", ActionFlags: flags}
+			if got := commonArgsFunc("uid", tt.ctx, model); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commonArgsFunc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	MarginFlag = "margin"
+)
+
+const (
+	// defaultOOMMargin is the memory in MB left above the current usage of the container
+	defaultOOMMargin = 4
+	// defaultOOMObserveTime is the seconds to wait for the oom event
+	defaultOOMObserveTime = 60
+	// oomKilledUnknown is the killed process reported if the OOM kill happened but the process is not found
+	oomKilledUnknown = "unknown"
+)
+
+type oomActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewOOMActionCommand() spec.ExpActionCommandSpec {
+	return &oomActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: MarginFlag,
+					Desc: "The memory left above the current usage of the container, unit is MB, default value is 4",
+				},
+				&spec.ExpFlag{
+					Name: ObserveTimeFlag,
+					Desc: "Seconds to wait for the OOM kill after the memory limit is lowered, default value is 60",
+				},
+			},
+			ActionExecutor: &oomActionExecutor{},
+			ActionExample: `# Lower the memory limit of the container to 4MB above the current usage and wait for the OOM kill
+blade create docker mem oom --container-id ee54f1e61c08
+
+# Leave 1MB above the current usage and wait for the OOM kill for 120 seconds
+blade create docker mem oom --margin 1 --observe-time 120 --container-id ee54f1e61c08`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*oomActionCommand) Name() string {
+	return "oom"
+}
+
+func (*oomActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*oomActionCommand) ShortDesc() string {
+	return "trigger the OOM kill in a container"
+}
+
+func (o *oomActionCommand) LongDesc() string {
+	if o.ActionLongDesc != "" {
+		return o.ActionLongDesc
+	}
+	return "lower the memory limit of the container just above the current usage, so the kernel OOM killer is triggered when the container allocates more memory, the original memory limit is restored when the experiment is destroyed"
+}
+
+type oomActionExecutor struct {
+}
+
+// oomResult is the OOM kill observed in the container by the oom event, the oom_kill count of the memory cgroup and
+// the state of the container, the kernel log is not used because it is shared by all containers of the host
+type oomResult struct {
+	ContainerId   string          `json:"containerId"`
+	Resources     resourcesResult `json:"resources"`
+	OOMHappened   bool            `json:"oomHappened"`
+	OOMEventTime  string          `json:"oomEventTime,omitempty"`
+	OOMKilledInit bool            `json:"oomKilledInit"`
+	KilledProcess string          `json:"killedProcess,omitempty"`
+	Status        string          `json:"status"`
+	RestartCount  int             `json:"restartCount"`
+	Restarted     bool            `json:"restarted"`
+}
+
+func (*oomActionExecutor) Name() string {
+	return "oom"
+}
+
+func (e *oomActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *oomActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			return restoreContainerResources(uid, suid, client, container.ID)
+		})
+	}
+	margin := int64(defaultOOMMargin)
+	if value := flags[MarginFlag]; value != "" {
+		margin, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || margin < 0 {
+			util.Errorf(uid, util.GetRunFuncName(), spec.ParameterIllegal.Sprintf(MarginFlag, value, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, MarginFlag, value, "it must be a non-negative integer")
+		}
+	}
+	observeTime := defaultOOMObserveTime
+	if value := flags[ObserveTimeFlag]; value != "" {
+		observeTime, err = strconv.Atoi(value)
+		if err != nil || observeTime < 1 {
+			util.Errorf(uid, util.GetRunFuncName(), spec.ParameterIllegal.Sprintf(ObserveTimeFlag, value, err))
+			return spec.ResponseFailWithFlags(spec.ParameterIllegal, ObserveTimeFlag, value, "it must be a positive integer")
+		}
+	}
+	// all the containers are observed until the same deadline
+	deadline := time.Now().Add(time.Duration(observeTime) * time.Second)
+	return execInContainersConcurrently(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		return e.triggerOOM(uid, client, container.ID, margin*1024*1024, deadline)
+	})
+}
+
+// triggerOOM lowers the memory limit of the container and waits for the oom event until the deadline, the container
+// is waited to be healthy again for the healthCheckTimeout after the deadline
+func (e *oomActionExecutor) triggerOOM(uid string, client *Client, containerId string,
+	margin int64, deadline time.Time) *spec.Response {
+	before, err := client.inspectContainer(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	stats, err := client.getContainerStats(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerStats", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerStats", err)
+	}
+	initPid := 0
+	if before.State != nil {
+		initPid = before.State.Pid
+	}
+	cgroupPath, err := getCgroupPath(initPid, "memory")
+	if err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("get the memory cgroup of the container failed, %v", err))
+	}
+	beforeSnapshot := getOOMSnapshot(cgroupPath)
+	since := time.Now()
+	response := updateContainerResources(uid, client, containerId, func(original container.Resources) (container.Resources, *spec.Response) {
+		memory := int64(stats.MemoryStats.Usage) + margin
+		if original.Memory > 0 && original.Memory <= memory {
+			return container.Resources{}, spec.ResponseFailWithFlags(spec.ParameterIllegal, MarginFlag, margin/1024/1024,
+				"the memory limit above the usage is not lower than the original limit")
+		}
+		// disable the swap so the container is killed instead of swapping
+		return container.Resources{Memory: memory, MemorySwap: memory}, spec.Success()
+	})
+	if !response.Success {
+		return response
+	}
+	result := oomResult{ContainerId: before.ID}
+	if resources, ok := response.Result.(resourcesResult); ok {
+		result.Resources = resources
+	}
+	message, happened, err := client.waitContainerEvent(before.ID, "oom", since, time.Until(deadline))
+	if err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("WaitOOMEvent", err))
+	}
+	if happened {
+		result.OOMHappened = true
+		result.OOMEventTime = time.Unix(0, message.TimeNano).Format(time.RFC3339Nano)
+	}
+	// the processes are listed before the container is restarted
+	afterSnapshot := getOOMSnapshot(cgroupPath)
+	if beforeSnapshot.OOMKills >= 0 && afterSnapshot.OOMKills > beforeSnapshot.OOMKills {
+		result.OOMHappened = true
+	}
+	after, err := client.waitContainerHealthy(before.ID, time.Until(deadline.Add(healthCheckTimeout)))
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	if after.State != nil {
+		result.Status = after.State.Status
+		result.OOMKilledInit = after.State.OOMKilled
+		result.OOMHappened = result.OOMHappened || after.State.OOMKilled
+	}
+	if result.OOMHappened {
+		result.KilledProcess = getKilledProcess(beforeSnapshot, afterSnapshot, initPid, result.OOMKilledInit)
+	}
+	result.RestartCount = after.RestartCount
+	result.Restarted = after.RestartCount > before.RestartCount ||
+		(before.State != nil && after.State != nil && after.State.StartedAt != before.State.StartedAt)
+	return spec.ReturnSuccess(result)
+}
+
+// oomSnapshot is the oom_kill count and the processes of the memory cgroup of the container, the OOMKills is -1 and
+// the Processes is nil if they can not be read
+type oomSnapshot struct {
+	OOMKills  int64
+	Processes map[int]string
+}
+
+// getOOMSnapshot reads the oom_kill count from the memory.events of the cgroup v2 or the memory.oom_control of the
+// cgroup v1, and the command names of the processes in the cgroup
+func getOOMSnapshot(cgroupPath string) oomSnapshot {
+	snapshot := oomSnapshot{OOMKills: -1}
+	if cgroupPath == "" {
+		return snapshot
+	}
+	file := "memory.oom_control"
+	if isCgroupV2() {
+		file = "memory.events"
+	}
+	if bytes, err := ioutil.ReadFile(path.Join(cgroupPath, file)); err == nil {
+		if count, err := parseOOMKillCount(string(bytes)); err == nil {
+			snapshot.OOMKills = count
+		}
+	}
+	bytes, err := ioutil.ReadFile(path.Join(cgroupPath, "cgroup.procs"))
+	if err != nil {
+		return snapshot
+	}
+	snapshot.Processes = make(map[int]string)
+	for _, field := range strings.Fields(string(bytes)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+		if err != nil {
+			// the process exits after the cgroup.procs is read
+			continue
+		}
+		snapshot.Processes[pid] = strings.TrimSpace(string(comm))
+	}
+	return snapshot
+}
+
+// parseOOMKillCount returns the value of the oom_kill line of the memory.events or memory.oom_control
+func parseOOMKillCount(content string) (int64, error) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("the oom_kill count is not found")
+}
+
+// getKilledProcess returns the processes killed by the OOM killer in the format of comm(pid). The init process is
+// returned if it is marked OOM killed by the docker, otherwise the processes which leave the cgroup after the OOM kill
+// are returned. The oomKilledUnknown is returned if the killed process can not be found.
+func getKilledProcess(before, after oomSnapshot, initPid int, initKilled bool) string {
+	if initKilled && initPid > 0 {
+		if comm, ok := before.Processes[initPid]; ok {
+			return fmt.Sprintf("%s(%d)", comm, initPid)
+		}
+		return fmt.Sprintf("(%d)", initPid)
+	}
+	if before.Processes == nil || after.Processes == nil {
+		return oomKilledUnknown
+	}
+	pids := make([]int, 0)
+	for pid := range before.Processes {
+		if _, ok := after.Processes[pid]; !ok {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return oomKilledUnknown
+	}
+	sort.Ints(pids)
+	killed := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		killed = append(killed, fmt.Sprintf("%s(%d)", before.Processes[pid], pid))
+	}
+	return strings.Join(killed, ",")
+}
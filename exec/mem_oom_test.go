@@ -0,0 +1,117 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import "testing"
+
+func TestParseOOMKillCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name:    "cgroup v2 memory.events",
+			content: "low 0\nhigh 0\nmax 12\noom 3\noom_kill 2\n",
+			want:    2,
+		},
+		{
+			name:    "cgroup v1 memory.oom_control",
+			content: "oom_kill_disable 0\nunder_oom 0\noom_kill 1\n",
+			want:    1,
+		},
+		{
+			name:    "oom_kill missing in the old kernel",
+			content: "oom_kill_disable 0\nunder_oom 0\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid count",
+			content: "oom_kill x\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOOMKillCount(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOOMKillCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseOOMKillCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetKilledProcess(t *testing.T) {
+	before := oomSnapshot{OOMKills: 0, Processes: map[int]string{1: "sh", 23: "java", 45: "stress"}}
+	tests := []struct {
+		name       string
+		before     oomSnapshot
+		after      oomSnapshot
+		initKilled bool
+		want       string
+	}{
+		{
+			name:   "child process killed",
+			before: before,
+			after:  oomSnapshot{OOMKills: 1, Processes: map[int]string{1: "sh", 23: "java"}},
+			want:   "stress(45)",
+		},
+		{
+			name:   "processes killed in the order of the pid",
+			before: before,
+			after:  oomSnapshot{OOMKills: 2, Processes: map[int]string{1: "sh"}},
+			want:   "java(23),stress(45)",
+		},
+		{
+			name:       "init process killed",
+			before:     before,
+			after:      oomSnapshot{OOMKills: -1},
+			initKilled: true,
+			want:       "sh(1)",
+		},
+		{
+			name:       "init process not listed",
+			before:     oomSnapshot{OOMKills: -1},
+			after:      oomSnapshot{OOMKills: -1},
+			initKilled: true,
+			want:       "(1)",
+		},
+		{
+			name:   "no process left the cgroup",
+			before: before,
+			after:  before,
+			want:   oomKilledUnknown,
+		},
+		{
+			name:   "cgroup not readable",
+			before: before,
+			after:  oomSnapshot{OOMKills: -1},
+			want:   oomKilledUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getKilledProcess(tt.before, tt.after, 1, tt.initKilled); got != tt.want {
+				t.Errorf("getKilledProcess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -48,22 +48,36 @@ func NewDockerExpModelSpec() *dockerExpModelSpec {
 		networkCommandModelSpec,
 	}
 
+	diskCommandModelSpec := newDiskCommandSpecForDocker()
 	execInContainerModelSpecs := []spec.ExpModelCommandSpec{
-		newProcessCommandModelSpecForDocker(),
-		newCpuCommandModelSpecForDocker(),
-		newDiskCommandSpecForDocker(),
-		newMemCommandModelSpecForDocker(),
+		diskCommandModelSpec,
 		newFileCommandSpecForDocker(),
 	}
+
+	memCommandModelSpec := newMemCommandModelSpecForDocker()
+	processCommandModelSpec := newProcessCommandModelSpecForDocker()
+	execInContainerOrSidecarModelSpecs := []spec.ExpModelCommandSpec{
+		processCommandModelSpec,
+		newCpuCommandModelSpecForDocker(),
+		memCommandModelSpec,
+	}
 	containerSelfModelSpec := NewContainerCommandSpec()
 
-	spec.AddExecutorToModelSpec(NewNetWorkSidecarExecutor(), networkCommandModelSpec)
-	spec.AddExecutorToModelSpec(NewRunCmdInContainerExecutorByCP(), execInContainerModelSpecs...)
+	spec.AddExecutorToModelSpec(NewNetworkExecModeExecutor(), networkCommandModelSpec)
+	// the actions using the docker api are added after the executors of the models are set
+	addActionsToModelSpec(networkCommandModelSpec, NewNetworkDisconnectActionCommand())
+	spec.AddExecutorToModelSpec(NewFileExecModeExecutor(), execInContainerModelSpecs...)
+	spec.AddExecutorToModelSpec(NewResourceExecModeExecutor(), execInContainerOrSidecarModelSpecs...)
+	addActionsToModelSpec(diskCommandModelSpec, NewThrottleActionCommand())
+	addActionsToModelSpec(memCommandModelSpec, NewOOMActionCommand())
+	addActionsToModelSpec(processCommandModelSpec, NewExhaustActionCommand())
 	spec.AddFlagsToModelSpec(GetExecSidecarFlags, execSidecarModelSpecs...)
 	spec.AddFlagsToModelSpec(GetContainerSelfFlags, containerSelfModelSpec)
 	spec.AddFlagsToModelSpec(GetExecInContainerFlags, execInContainerModelSpecs...)
+	spec.AddFlagsToModelSpec(GetExecInContainerFlags, execInContainerOrSidecarModelSpecs...)
 
 	expModelCommandSpecs := append(execSidecarModelSpecs, execInContainerModelSpecs...)
+	expModelCommandSpecs = append(expModelCommandSpecs, execInContainerOrSidecarModelSpecs...)
 	expModelCommandSpecs = append(expModelCommandSpecs, containerSelfModelSpec)
 	modelSpec.addExpModels(expModelCommandSpecs...)
 	return modelSpec
@@ -124,6 +138,20 @@ blade create docker network loss --percent 100 --interface eth0 --remote-port 80
 	return networkCommandModelSpec
 }
 
+// addActionsToModelSpec appends the docker actions to the model spec from chaosblade-exec-os
+func addActionsToModelSpec(modelSpec spec.ExpModelCommandSpec, actions ...spec.ExpActionCommandSpec) {
+	switch v := modelSpec.(type) {
+	case *network.NetworkCommandSpec:
+		v.ExpActions = append(v.ExpActions, actions...)
+	case *disk.DiskCommandSpec:
+		v.ExpActions = append(v.ExpActions, actions...)
+	case *mem.MemCommandModelSpec:
+		v.ExpActions = append(v.ExpActions, actions...)
+	case *process.ProcessCommandModelSpec:
+		v.ExpActions = append(v.ExpActions, actions...)
+	}
+}
+
 func newFileCommandSpecForDocker() spec.ExpModelCommandSpec {
 	fileCommandSpec := file.NewFileCommandSpec()
 	for _, action := range fileCommandSpec.Actions() {
@@ -272,7 +300,13 @@ blade create docker cpu load --cpu-list 0,3 --chaosblade-release /root/chaosblad
 blade create docker cpu load --cpu-list 1-3 --chaosblade-release /root/chaosblade-0.6.0.tar.gz --container-id ee54f1e61c08
 
 # Specified percentage load in the container
-blade create docker cpu load --cpu-percent 60 --chaosblade-release /root/chaosblade-0.6.0.tar.gz --container-id ee54f1e61c08`)
+blade create docker cpu load --cpu-percent 60 --chaosblade-release /root/chaosblade-0.6.0.tar.gz --container-id ee54f1e61c08
+
+# Create the CPU load in the chaosblade-tool sidecar of the container, the target container is not modified
+blade create docker cpu load --cpu-percent 60 --exec-mode sidecar --container-id ee54f1e61c08
+
+# Create the CPU load by the first mode which can work for the container
+blade create docker cpu load --cpu-percent 60 --exec-mode auto --container-id ee54f1e61c08`)
 		}
 	}
 	return cpuCommandModelSpec
@@ -377,12 +411,61 @@ var ContainerIdFlag = &spec.ExpFlag{
 
 var ContainerNameFlag = &spec.ExpFlag{
 	Name:                  "container-name",
-	Desc:                  "Container name, when used with container-id, container-id is preferred",
+	Desc:                  "Container name, the name is matched exactly, when used with container-id, container-id is preferred",
+	NoArgs:                false,
+	Required:              false,
+	RequiredWhenDestroyed: false,
+}
+
+var ContainerNameRegexFlag = &spec.ExpFlag{
+	Name:   "container-name-regex",
+	Desc:   "Match the container-name as a regular expression instead of the exact name, the expression must match the whole name of only one container",
+	NoArgs: true,
+}
+
+var ContainerLabelFlag = &spec.ExpFlag{
+	Name:                  "container-label",
+	Desc:                  "Container labels, for example, app=web,tier=frontend. All running containers matching the labels are selected, ignored if container-id or container-name is specified",
 	NoArgs:                false,
 	Required:              false,
 	RequiredWhenDestroyed: false,
 }
 
+var ComposeProjectFlag = &spec.ExpFlag{
+	Name:     "compose-project",
+	Desc:     "Docker compose project name, selects the containers by the com.docker.compose.project label",
+	NoArgs:   false,
+	Required: false,
+}
+
+var ComposeServiceFlag = &spec.ExpFlag{
+	Name:     "compose-service",
+	Desc:     "Docker compose service name, selects the containers by the com.docker.compose.service label",
+	NoArgs:   false,
+	Required: false,
+}
+
+var ContainerCountFlag = &spec.ExpFlag{
+	Name:     "container-count",
+	Desc:     "The number of containers picked from the containers matched by the selector, if both container-count and container-percent are specified, the smaller one is used",
+	NoArgs:   false,
+	Required: false,
+}
+
+var ContainerPercentFlag = &spec.ExpFlag{
+	Name:     "container-percent",
+	Desc:     "The percentage of containers picked from the containers matched by the selector, the value range is [1, 100]",
+	NoArgs:   false,
+	Required: false,
+}
+
+var SelectionSeedFlag = &spec.ExpFlag{
+	Name:     "selection-seed",
+	Desc:     "The random seed used to pick containers, the same seed picks the same containers from the same candidates",
+	NoArgs:   false,
+	Required: false,
+}
+
 var ImageRepoFlag = &spec.ExpFlag{
 	Name:     "image-repo",
 	Desc:     "Image repository of the chaosblade-tool",
@@ -406,7 +489,7 @@ var EndpointFlag = &spec.ExpFlag{
 
 var ChaosBladeReleaseFlag = &spec.ExpFlag{
 	Name: "chaosblade-release",
-	Desc: "The pull path of the chaosblade tar package, for example, --chaosblade-release /opt/chaosblade-0.4.0.tar.gz",
+	Desc: "The pull path of the chaosblade tar package, the .tar, .tar.gz and unpacked directory are supported, for example, --chaosblade-release /opt/chaosblade-0.4.0.tar.gz. The default is /opt/chaosblade-<version>-<os>-<arch>.tar.gz matching the platform of the container image, or /opt/chaosblade-<version>.tar.gz built for the host",
 }
 
 var ChaosBladeOverrideFlag = &spec.ExpFlag{
@@ -415,10 +498,40 @@ var ChaosBladeOverrideFlag = &spec.ExpFlag{
 	NoArgs: true,
 }
 
+var ExecModeFlag = &spec.ExpFlag{
+	Name: "exec-mode",
+	Desc: "The mode to execute the experiment, cp copies the chaosblade tool to the target container, sidecar executes in the chaosblade-tool sidecar which joins the namespaces and the cgroup of the target container, nsenter executes the chaos_os tool of the host in the namespaces and the cgroup of the target container, auto uses the first mode which can work. The supported modes depend on the experiment target, default value is sidecar for network, cp for others",
+}
+
+var ResidentSidecarFlag = &spec.ExpFlag{
+	Name:   "resident-sidecar",
+	Desc:   "Keep the network sidecar alive until the experiment is destroyed, so the experiment is destroyed in the same sidecar without the chaosblade-tool image, default value is false",
+	NoArgs: true,
+}
+
+var ChaosBladeFromImageFlag = &spec.ExpFlag{
+	Name:   "chaosblade-from-image",
+	Desc:   "Copy the chaosblade tool from the chaosblade-tool image to the target container instead of the chaosblade-release file",
+	NoArgs: true,
+}
+
+var ChaosBladeCleanupFlag = &spec.ExpFlag{
+	Name:   "chaosblade-cleanup",
+	Desc:   "Remove the chaosblade tool from the target container after the experiment is destroyed if no other experiment in the container is running, default value is false",
+	NoArgs: true,
+}
+
 func GetContainerSelfFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		ContainerNameRegexFlag,
+		ContainerLabelFlag,
+		ComposeProjectFlag,
+		ComposeServiceFlag,
+		ContainerCountFlag,
+		ContainerPercentFlag,
+		SelectionSeedFlag,
 		EndpointFlag,
 	}
 }
@@ -427,9 +540,18 @@ func GetExecSidecarFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		ContainerNameRegexFlag,
+		ContainerLabelFlag,
+		ComposeProjectFlag,
+		ComposeServiceFlag,
+		ContainerCountFlag,
+		ContainerPercentFlag,
+		SelectionSeedFlag,
 		ImageRepoFlag,
 		ImageVersionFlag,
 		EndpointFlag,
+		ExecModeFlag,
+		ResidentSidecarFlag,
 	}
 }
 
@@ -437,17 +559,27 @@ func GetExecInContainerFlags() []spec.ExpFlagSpec {
 	return []spec.ExpFlagSpec{
 		ContainerIdFlag,
 		ContainerNameFlag,
+		ContainerNameRegexFlag,
+		ContainerLabelFlag,
+		ComposeProjectFlag,
+		ComposeServiceFlag,
+		ContainerCountFlag,
+		ContainerPercentFlag,
+		SelectionSeedFlag,
 		ImageRepoFlag,
 		ImageVersionFlag,
 		EndpointFlag,
 		ChaosBladeReleaseFlag,
 		ChaosBladeOverrideFlag,
+		ChaosBladeFromImageFlag,
+		ChaosBladeCleanupFlag,
+		ExecModeFlag,
 	}
 }
 
 func GetAllDockerFlagNames() map[string]spec.Empty {
 	flagNames := make(map[string]spec.Empty, 0)
-	for _, flag := range GetExecInContainerFlags() {
+	for _, flag := range append(GetExecInContainerFlags(), GetExecSidecarFlags()...) {
 		flagNames[flag.FlagName()] = spec.Empty{}
 	}
 	return flagNames
@@ -0,0 +1,195 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+)
+
+const (
+	DockerNetworkFlag = "docker-network"
+)
+
+// recordKeyDisconnectedNetworks is the record key prefix of the networks disconnected from the container
+const recordKeyDisconnectedNetworks = "disconnected-networks"
+
+type networkDisconnectActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewNetworkDisconnectActionCommand() spec.ExpActionCommandSpec {
+	return &networkDisconnectActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: DockerNetworkFlag,
+					Desc: "The docker network name or id which the container is disconnected from, all networks of the container if not specified",
+				},
+				&spec.ExpFlag{
+					Name:   ForceFlag,
+					Desc:   "Force the container to disconnect from the network",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &networkDisconnectActionExecutor{},
+			ActionExample: `# Disconnect the container from the docker network app-net
+blade create docker network disconnect --docker-network app-net --container-id ee54f1e61c08
+
+# Disconnect the container from all docker networks
+blade create docker network disconnect --container-id ee54f1e61c08`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*networkDisconnectActionCommand) Name() string {
+	return "disconnect"
+}
+
+func (*networkDisconnectActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*networkDisconnectActionCommand) ShortDesc() string {
+	return "disconnect a container from the docker network"
+}
+
+func (n *networkDisconnectActionCommand) LongDesc() string {
+	if n.ActionLongDesc != "" {
+		return n.ActionLongDesc
+	}
+	return "disconnect the container from the docker network to simulate the network partition, the container is connected again with the same endpoint settings when the experiment is destroyed"
+}
+
+type networkDisconnectActionExecutor struct {
+}
+
+func (*networkDisconnectActionExecutor) Name() string {
+	return "disconnect"
+}
+
+func (e *networkDisconnectActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *networkDisconnectActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			return e.reconnect(uid, suid, client, container.ID)
+		})
+	}
+	dockerNetwork := flags[DockerNetworkFlag]
+	force := flags[ForceFlag] == "true"
+	return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		return e.disconnect(uid, client, container.ID, dockerNetwork, force)
+	})
+}
+
+// disconnect records the endpoint settings of the networks and disconnects the container from them
+func (e *networkDisconnectActionExecutor) disconnect(uid string, client *Client, containerId, dockerNetwork string,
+	force bool) *spec.Response {
+	containerJSON, err := client.inspectContainer(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	var networks map[string]*network.EndpointSettings
+	if containerJSON.NetworkSettings != nil {
+		networks = getDisconnectedNetworks(containerJSON.NetworkSettings.Networks, dockerNetwork, containerJSON.ID)
+	}
+	if len(networks) == 0 {
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the container %s is not connected to %s", containerId, dockerNetwork))
+		return spec.ResponseFailWithFlags(spec.ParameterInvalid, DockerNetworkFlag, dockerNetwork,
+			"the container is not connected to the network")
+	}
+	recordKey := fmt.Sprintf("%s-%s", recordKeyDisconnectedNetworks, containerJSON.ID)
+	if err := saveRecord(uid, recordKey, networks); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SaveNetworks", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveNetworks", err)
+	}
+	disconnected := make([]string, 0, len(networks))
+	for name := range networks {
+		if err := client.disconnectNetwork(name, containerJSON.ID, force); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("NetworkDisconnect", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "NetworkDisconnect", err)
+		}
+		disconnected = append(disconnected, name)
+	}
+	return spec.ReturnSuccess(disconnected)
+}
+
+// getDisconnectedNetworks returns the endpoint configs of the networks matched by the name or the id, all the networks
+// are matched if the dockerNetwork is empty
+func getDisconnectedNetworks(connected map[string]*network.EndpointSettings, dockerNetwork,
+	containerId string) map[string]*network.EndpointSettings {
+	networks := make(map[string]*network.EndpointSettings)
+	for name, settings := range connected {
+		if dockerNetwork == "" || dockerNetwork == name || (settings != nil && dockerNetwork == settings.NetworkID) {
+			networks[name] = getEndpointConfig(settings, containerId)
+		}
+	}
+	return networks
+}
+
+// reconnect connects the container to the recorded networks with the same endpoint settings
+func (e *networkDisconnectActionExecutor) reconnect(uid, suid string, client *Client, containerId string) *spec.Response {
+	recordKey := fmt.Sprintf("%s-%s", recordKeyDisconnectedNetworks, containerId)
+	networks := make(map[string]*network.EndpointSettings)
+	exists, err := loadRecord(suid, recordKey, &networks)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadNetworks", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadNetworks", err)
+	}
+	if !exists {
+		return spec.ReturnSuccess(uid)
+	}
+	containerJSON, err := client.inspectContainer(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerInspect", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerInspect", err)
+	}
+	connected := make([]string, 0, len(networks))
+	for name, settings := range networks {
+		if containerJSON.NetworkSettings != nil {
+			if _, ok := containerJSON.NetworkSettings.Networks[name]; ok {
+				// the container has been connected to the network again
+				continue
+			}
+		}
+		if err := client.connectNetwork(name, containerId, settings); err != nil {
+			util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("NetworkConnect", err))
+			return spec.ResponseFailWithFlags(spec.DockerExecFailed, "NetworkConnect", err)
+		}
+		connected = append(connected, name)
+	}
+	if err := removeRecord(suid, recordKey); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the networks record of %s failed, %v", suid, err))
+	}
+	return spec.ReturnSuccess(connected)
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestGetDisconnectedNetworks(t *testing.T) {
+	connected := map[string]*network.EndpointSettings{
+		"bridge":       {NetworkID: "bridge-id"},
+		"shop_default": {NetworkID: "shop-id", Aliases: []string{"web"}},
+		"none":         nil,
+	}
+	tests := []struct {
+		name          string
+		dockerNetwork string
+		want          []string
+	}{
+		{name: "all the networks", want: []string{"bridge", "none", "shop_default"}},
+		{name: "by the name", dockerNetwork: "shop_default", want: []string{"shop_default"}},
+		{name: "by the id", dockerNetwork: "bridge-id", want: []string{"bridge"}},
+		{name: "not connected", dockerNetwork: "other", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks := getDisconnectedNetworks(connected, tt.dockerNetwork, "0123456789abcdef")
+			got := make([]string, 0, len(networks))
+			for name := range networks {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getDisconnectedNetworks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// the endpoint configs are recorded without the operational data
+	networks := getDisconnectedNetworks(connected, "shop_default", "0123456789abcdef")
+	want := &network.EndpointSettings{Aliases: []string{"web"}}
+	if !reflect.DeepEqual(networks["shop_default"], want) {
+		t.Errorf("getDisconnectedNetworks() config = %+v, want %+v", networks["shop_default"], want)
+	}
+}
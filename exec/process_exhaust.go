@@ -0,0 +1,251 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/spec"
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	FillFlag = "fill"
+)
+
+// recordKeyIdleProcesses is the record key prefix of the idle processes spawned in the container
+const recordKeyIdleProcesses = "idle-processes"
+
+type exhaustActionCommand struct {
+	spec.BaseExpActionCommandSpec
+}
+
+func NewExhaustActionCommand() spec.ExpActionCommandSpec {
+	return &exhaustActionCommand{
+		spec.BaseExpActionCommandSpec{
+			ActionMatchers: []spec.ExpFlagSpec{},
+			ActionFlags: []spec.ExpFlagSpec{
+				&spec.ExpFlag{
+					Name: PidsLimitFlag,
+					Desc: "The max number of processes in the container, default value is the current number of processes",
+				},
+				&spec.ExpFlag{
+					Name:   FillFlag,
+					Desc:   "Spawn idle processes in the container until the pids limit is reached",
+					NoArgs: true,
+				},
+			},
+			ActionExecutor: &exhaustActionExecutor{},
+			ActionExample: `# Forbid the container to create new processes
+blade create docker process exhaust --container-id ee54f1e61c08
+
+# Limit the container to 50 processes and spawn idle processes until the limit is reached
+blade create docker process exhaust --pids-limit 50 --fill --container-id ee54f1e61c08`,
+			ActionCategories: []string{CategorySystemContainer},
+		},
+	}
+}
+
+func (*exhaustActionCommand) Name() string {
+	return "exhaust"
+}
+
+func (*exhaustActionCommand) Aliases() []string {
+	return []string{}
+}
+
+func (*exhaustActionCommand) ShortDesc() string {
+	return "exhaust the pids of a container"
+}
+
+func (e *exhaustActionCommand) LongDesc() string {
+	if e.ActionLongDesc != "" {
+		return e.ActionLongDesc
+	}
+	return "lower the pids limit of the container so the fork fails, the idle processes are spawned until the limit is reached if the fill flag is specified, the original limit is restored and the idle processes are killed when the experiment is destroyed"
+}
+
+type exhaustActionExecutor struct {
+}
+
+// exhaustResult is the pids limit and the idle processes of the container
+type exhaustResult struct {
+	ContainerId   string          `json:"containerId"`
+	Resources     resourcesResult `json:"resources"`
+	Processes     uint64          `json:"processes"`
+	IdleProcesses []string        `json:"idleProcesses,omitempty"`
+}
+
+func (*exhaustActionExecutor) Name() string {
+	return "exhaust"
+}
+
+func (e *exhaustActionExecutor) SetChannel(channel spec.Channel) {
+}
+
+func (e *exhaustActionExecutor) Exec(uid string, ctx context.Context, model *spec.ExpModel) *spec.Response {
+	flags := model.ActionFlags
+	client, err := GetClient(flags[EndpointFlag.Name])
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("GetClient", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "GetClient", err)
+	}
+	if suid, ok := spec.IsDestroy(ctx); ok {
+		return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+			return e.recover(uid, suid, client, container.ID)
+		})
+	}
+	if err := client.checkAPIVersion(pidsLimitAPIVersion, "update the pids limit"); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("CheckAPIVersion", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "CheckAPIVersion", err)
+	}
+	pidsLimit, response := parsePositiveInt64(flags, PidsLimitFlag)
+	if !response.Success {
+		util.Errorf(uid, util.GetRunFuncName(), response.Err)
+		return response
+	}
+	fill := flags[FillFlag] == "true"
+	return execInContainers(uid, ctx, client, model, func(container types.Container) *spec.Response {
+		return e.exhaust(uid, client, container.ID, pidsLimit, fill)
+	})
+}
+
+// exhaust lowers the pids limit of the container and spawns the idle processes if fill is true
+func (e *exhaustActionExecutor) exhaust(uid string, client *Client, containerId string, pidsLimit int64,
+	fill bool) *spec.Response {
+	stats, err := client.getContainerStats(containerId)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerStats", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerStats", err)
+	}
+	current := stats.PidsStats.Current
+	if current == 0 {
+		// there is at least the init process in the container if the pids cgroup is enabled
+		util.Errorf(uid, util.GetRunFuncName(), fmt.Sprintf("the pids cgroup of %s is not found", containerId))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerStats",
+			"the pids cgroup is not enabled for the container")
+	}
+	if pidsLimit == 0 {
+		pidsLimit = int64(current)
+	}
+	response := updateContainerResources(uid, client, containerId, func(original container.Resources) (container.Resources, *spec.Response) {
+		return container.Resources{PidsLimit: pidsLimit}, spec.Success()
+	})
+	if !response.Success {
+		return response
+	}
+	result := exhaustResult{ContainerId: containerId, Processes: current}
+	if resources, ok := response.Result.(resourcesResult); ok {
+		result.ContainerId = resources.ContainerId
+		result.Resources = resources
+	}
+	if fill && pidsLimit > int64(current) {
+		result.IdleProcesses, response = e.spawnIdleProcesses(uid, client, result.ContainerId, pidsLimit-int64(current))
+		if !response.Success {
+			restoreContainerResources(uid, uid, client, result.ContainerId)
+			return response
+		}
+	}
+	return spec.ReturnSuccess(result)
+}
+
+// spawnIdleProcesses starts the sleep processes in the container, the spawn stops when the fork fails
+func (e *exhaustActionExecutor) spawnIdleProcesses(uid string, client *Client, containerId string,
+	count int64) ([]string, *spec.Response) {
+	output, err := client.execContainer(containerId, getSpawnIdleProcessesCommand(count))
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerExec", err))
+		return nil, spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerExec", err)
+	}
+	pids := strings.Fields(output)
+	recordKey := fmt.Sprintf("%s-%s", recordKeyIdleProcesses, containerId)
+	if err := saveRecord(uid, recordKey, pids); err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("SaveIdleProcesses", err))
+		e.killIdleProcesses(uid, client, containerId, pids)
+		return nil, spec.ResponseFailWithFlags(spec.DockerExecFailed, "SaveIdleProcesses", err)
+	}
+	return pids, spec.Success()
+}
+
+// recover restores the pids limit first so that the kill command can be executed in the container
+func (e *exhaustActionExecutor) recover(uid, suid string, client *Client, containerId string) *spec.Response {
+	response := restoreContainerResources(uid, suid, client, containerId)
+	if !response.Success {
+		return response
+	}
+	recordKey := fmt.Sprintf("%s-%s", recordKeyIdleProcesses, containerId)
+	pids := make([]string, 0)
+	exists, err := loadRecord(suid, recordKey, &pids)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("LoadIdleProcesses", err))
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "LoadIdleProcesses", err)
+	}
+	if !exists {
+		return response
+	}
+	if err := e.killIdleProcesses(uid, client, containerId, pids); err != nil {
+		return spec.ResponseFailWithFlags(spec.DockerExecFailed, "ContainerExec", err)
+	}
+	if err := removeRecord(suid, recordKey); err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), fmt.Sprintf("remove the idle processes record of %s failed, %v", suid, err))
+	}
+	return response
+}
+
+// killIdleProcesses kills the spawned sleep processes in the container
+func (e *exhaustActionExecutor) killIdleProcesses(uid string, client *Client, containerId string, pids []string) error {
+	command, err := getKillIdleProcessesCommand(pids)
+	if err != nil {
+		util.Warnf(uid, util.GetRunFuncName(), err.Error())
+		return err
+	}
+	if command == "" {
+		return nil
+	}
+	_, err = client.execContainer(containerId, command)
+	if err != nil {
+		util.Errorf(uid, util.GetRunFuncName(), spec.DockerExecFailed.Sprintf("ContainerExec", err))
+	}
+	return err
+}
+
+// getSpawnIdleProcessesCommand returns the command which starts the sleep processes in the background and prints
+// their pids, the spawn stops when the fork fails
+func getSpawnIdleProcessesCommand(count int64) string {
+	return fmt.Sprintf(
+		`i=0; last=; while [ $i -lt %d ]; do sleep 2147483647 >/dev/null 2>&1 & [ "$!" = "$last" ] && break; last=$!; echo $last; i=$((i+1)); done 2>/dev/null`,
+		count)
+}
+
+// getKillIdleProcessesCommand returns the command which kills the idle processes, or empty if there is no process
+func getKillIdleProcessesCommand(pids []string) (string, error) {
+	for _, pid := range pids {
+		if _, err := strconv.Atoi(pid); err != nil {
+			return "", fmt.Errorf("illegal pid %s of the idle process", pid)
+		}
+	}
+	if len(pids) == 0 {
+		return "", nil
+	}
+	// the processes may be killed already, so ignore the error output
+	return fmt.Sprintf("kill -9 %s 2>/dev/null; true", strings.Join(pids, " ")), nil
+}
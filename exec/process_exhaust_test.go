@@ -0,0 +1,72 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestIdleProcessesCommands(t *testing.T) {
+	output, err := exec.Command("/bin/sh", "-c", getSpawnIdleProcessesCommand(3)).Output()
+	if err != nil {
+		t.Fatalf("spawn the idle processes error = %v", err)
+	}
+	pids := strings.Fields(string(output))
+	if len(pids) != 3 {
+		t.Fatalf("spawned pids = %v, want 3 pids", pids)
+	}
+	command, err := getKillIdleProcessesCommand(pids)
+	if err != nil {
+		t.Fatalf("getKillIdleProcessesCommand() error = %v", err)
+	}
+	if err := exec.Command("/bin/sh", "-c", command).Run(); err != nil {
+		t.Fatalf("kill the idle processes error = %v", err)
+	}
+	for _, pid := range pids {
+		// the killed process exits, or it is a zombie until it is reaped by the init process
+		state, _ := exec.Command("ps", "-o", "stat=", "-p", pid).Output()
+		if stat := strings.TrimSpace(string(state)); stat != "" && !strings.HasPrefix(stat, "Z") {
+			t.Errorf("the idle process %s is not killed, the state is %s", pid, stat)
+		}
+	}
+}
+
+func TestGetKillIdleProcessesCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		pids    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "no process", pids: []string{}, want: ""},
+		{name: "processes", pids: []string{"12", "13"}, want: "kill -9 12 13 2>/dev/null; true"},
+		{name: "illegal pid", pids: []string{"12", "13; rm -rf /"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getKillIdleProcessesCommand(tt.pids)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getKillIdleProcessesCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getKillIdleProcessesCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 1999-2019 Alibaba Group Holding Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package exec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/chaosblade-io/chaosblade-spec-go/util"
+)
+
+// recordDirName is the directory under the blade home which stores the experiment records
+const recordDirName = "docker-experiments"
+
+// recordKeyContainers is the record key of the containers injected by the experiment
+const recordKeyContainers = "containers"
+
+// getRecordDir returns the record directory of the experiment
+func getRecordDir(uid string) string {
+	return path.Join(util.GetProgramPath(), recordDirName, uid)
+}
+
+// saveRecord stores the value of the experiment by key, so that the destroy command can get it
+func saveRecord(uid, key string, value interface{}) error {
+	dir := getRecordDir(uid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, key+".json"), bytes, 0644)
+}
+
+// loadRecord reads the value of the experiment by key, returns false if the record does not exist
+func loadRecord(uid, key string, value interface{}) (bool, error) {
+	bytes, err := ioutil.ReadFile(path.Join(getRecordDir(uid), key+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, json.Unmarshal(bytes, value)
+}
+
+// removeRecord deletes the value of the experiment by key
+func removeRecord(uid, key string) error {
+	err := os.Remove(path.Join(getRecordDir(uid), key+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeRecords deletes all the records of the experiment
+func removeRecords(uid string) error {
+	return os.RemoveAll(getRecordDir(uid))
+}